@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Only lets through requests carrying the admin token, as 'Authorization: Bearer <token>'
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin token required"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func registerAdminRoutes(router *gin.Engine, token string) {
+	admin := router.Group("admin", adminAuth(token))
+
+	admin.GET("aliases", handleGetAliases)
+	// Names go in the body or query, as they can contain slashes, e.g. 'AC/DC'
+	admin.PUT("aliases/:field", handleSetAliases)
+	admin.DELETE("aliases/:field", handleDeleteAliases)
+	admin.GET("near-misses", handleGetNearMisses)
+	admin.POST("near-misses/:id/promote", handlePromoteNearMiss)
+}
+
+func handleGetAliases(c *gin.Context) {
+	c.JSON(http.StatusOK, aliases.all())
+}
+
+// Body: {"name": "-M-", "aliases": ["Matthieu Chedid", "M"]}
+func handleSetAliases(c *gin.Context) {
+	var body struct {
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fields 'name' and 'aliases' required"})
+		return
+	}
+
+	if err := aliases.set(c.Param("field"), body.Name, body.Aliases); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, aliases.all())
+}
+
+func handleDeleteAliases(c *gin.Context) {
+	if err := aliases.set(c.Param("field"), c.Query("name"), nil); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, aliases.all())
+}
+
+func handleGetNearMisses(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"near_misses": aliases.getNearMisses()})
+}
+
+func handlePromoteNearMiss(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+
+	if err := aliases.promote(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, aliases.all())
+}
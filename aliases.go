@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hbakhtiyor/strsim"
+	"gopkg.in/yaml.v2"
+)
+
+// Guesses this similar to an answer, without matching it, are logged as near misses
+const nearMissThreshold = 0.6
+
+// Keeping more near misses would only bury the recent ones
+const maxNearMisses = 500
+
+// Other names players know artists and titles by, shared by every room.
+// Loaded from a YAML or JSON file, which edits are saved to.
+type AliasDictionary struct {
+	mu   sync.RWMutex
+	path string
+	// Aliases by canonical name, for each field
+	aliases map[string]map[string][]string
+	// Canonical names, by sanitized name or alias, for each field
+	index      map[string]map[string]string
+	nearMisses []NearMiss
+	nextID     int
+}
+
+// Layout of the alias file
+type aliasFile struct {
+	Artists map[string][]string `json:"artists" yaml:"artists"`
+	Titles  map[string][]string `json:"titles" yaml:"titles"`
+}
+
+// A guess which scored just under the match threshold
+type NearMiss struct {
+	ID         int     `json:"id"`
+	Field      string  `json:"field"`
+	Guess      string  `json:"guess"`
+	Answer     string  `json:"answer"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Used when no alias file is configured
+var aliases = newAliasDictionary("")
+
+func newAliasDictionary(path string) *AliasDictionary {
+	d := &AliasDictionary{
+		path: path,
+		aliases: map[string]map[string][]string{
+			artistField: make(map[string][]string),
+			titleField:  make(map[string][]string),
+		},
+		nearMisses: make([]NearMiss, 0),
+		nextID:     1,
+	}
+	d.reindex()
+
+	return d
+}
+
+// Reads the alias file at path. A missing file gives an empty dictionary, created on the first edit.
+func loadAliasDictionary(path string) (*AliasDictionary, error) {
+	d := newAliasDictionary(path)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file aliasFile
+	if isYAMLFile(path) {
+		err = yaml.Unmarshal(data, &file)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid alias file. Err: %v", err)
+	}
+
+	for name, names := range file.Artists {
+		d.aliases[artistField][name] = names
+	}
+	for name, names := range file.Titles {
+		d.aliases[titleField][name] = names
+	}
+	d.reindex()
+
+	log.Printf("%v artist and %v title aliases loaded from %v", len(file.Artists), len(file.Titles), path)
+
+	return d, nil
+}
+
+func isYAMLFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".yaml" || extension == ".yml"
+}
+
+func (d *AliasDictionary) reindex() {
+	d.index = make(map[string]map[string]string)
+
+	for field, byName := range d.aliases {
+		d.index[field] = make(map[string]string)
+		for name, names := range byName {
+			d.index[field][sanitizeString(name)] = name
+			for _, alias := range names {
+				d.index[field][sanitizeString(alias)] = name
+			}
+		}
+	}
+}
+
+// Returns answers along with their aliases. Aliases go both ways: an alias of an answer
+// counts, and so does the canonical name an answer is an alias of.
+func (d *AliasDictionary) expand(field string, answers []string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	expanded := append([]string{}, answers...)
+	for _, answer := range answers {
+		name, ok := d.index[field][sanitizeString(answer)]
+		if !ok {
+			continue
+		}
+
+		expanded = append(expanded, name)
+		expanded = append(expanded, d.aliases[field][name]...)
+	}
+
+	return uniqueAnswers(expanded...)
+}
+
+// Returns the aliases of every field, by canonical name
+func (d *AliasDictionary) all() aliasFile {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return aliasFile{Artists: copyAliases(d.aliases[artistField]), Titles: copyAliases(d.aliases[titleField])}
+}
+
+func copyAliases(byName map[string][]string) map[string][]string {
+	copied := make(map[string][]string, len(byName))
+	for name, names := range byName {
+		copied[name] = append([]string{}, names...)
+	}
+	return copied
+}
+
+// Replaces the aliases of name, removing it when there are none left
+func (d *AliasDictionary) set(field, name string, names []string) error {
+	if _, ok := d.aliases[field]; !ok {
+		return fmt.Errorf("Unknown field '%v'", field)
+	}
+	if strings.TrimSpace(name) == "" {
+		return errors.New("Name required")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(names) == 0 {
+		delete(d.aliases[field], name)
+	} else {
+		d.aliases[field][name] = names
+	}
+	d.reindex()
+
+	return d.save()
+}
+
+// Writes the dictionary back to its file, in the format it was read from.
+// Must be called with the lock held.
+func (d *AliasDictionary) save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	file := aliasFile{Artists: d.aliases[artistField], Titles: d.aliases[titleField]}
+
+	var data []byte
+	var err error
+	if isYAMLFile(d.path) {
+		data, err = yaml.Marshal(file)
+	} else {
+		data, err = json.MarshalIndent(file, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(d.path, data, 0644)
+}
+
+func (d *AliasDictionary) recordNearMiss(nearMiss NearMiss) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	nearMiss.ID = d.nextID
+	d.nextID++
+
+	d.nearMisses = append(d.nearMisses, nearMiss)
+	if len(d.nearMisses) > maxNearMisses {
+		d.nearMisses = d.nearMisses[1:]
+	}
+
+	log.Printf("Near miss on %v '%v': '%v' (%.2f)", nearMiss.Field, nearMiss.Answer, nearMiss.Guess, nearMiss.Similarity)
+}
+
+func (d *AliasDictionary) getNearMisses() []NearMiss {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]NearMiss{}, d.nearMisses...)
+}
+
+// Makes the guess of a near miss an alias of its answer, and drops it from the log
+func (d *AliasDictionary) promote(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, nearMiss := range d.nearMisses {
+		if nearMiss.ID != id {
+			continue
+		}
+
+		// The answer may itself be an alias
+		name := nearMiss.Answer
+		if canonical, ok := d.index[nearMiss.Field][sanitizeString(name)]; ok {
+			name = canonical
+		}
+
+		d.aliases[nearMiss.Field][name] = append(d.aliases[nearMiss.Field][name], nearMiss.Guess)
+		d.nearMisses = append(d.nearMisses[:i], d.nearMisses[i+1:]...)
+		d.reindex()
+
+		return d.save()
+	}
+
+	return errors.New("Near miss not found")
+}
+
+// Returns the answer for field the guess is the most similar to, and their similarity
+func (g *Guess) closest(field string) (string, float64) {
+	best, bestSimilarity := "", 0.0
+	for _, answer := range g.answers(field) {
+		similarity := strsim.Compare(g.Guess, sanitizeString(answer))
+		if similarity > bestSimilarity {
+			best, bestSimilarity = answer, similarity
+		}
+	}
+
+	return best, bestSimilarity
+}
+
+// Logs the guess if it was close to one of the answers for field, returns its similarity
+func (g *Guess) logNearMiss(field string) float64 {
+	answer, similarity := g.closest(field)
+
+	if similarity >= nearMissThreshold && similarity <= matchThreshold {
+		aliases.recordNearMiss(NearMiss{Field: field, Guess: g.Raw, Answer: answer, Similarity: similarity})
+	}
+
+	return similarity
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// Snapshots of the rooms, without their round history, by room code
+	boltRoomsBucket = []byte("rooms")
+	// A bucket per room, holding the result of each round of its match by round number
+	boltRoundsBucket = []byte("rounds")
+)
+
+// BoltStore saves room snapshots to an embedded BoltDB database, the rounds of a match being
+// stored one by one
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func newBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltRoomsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltRoundsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func boltRoundKey(nb int) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(nb))
+	return key
+}
+
+func (b *BoltStore) Save(snapshot RoomSnapshot) error {
+	rounds := snapshot.Rounds
+	snapshot.Rounds = nil
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltRoomsBucket).Put([]byte(snapshot.Code), data); err != nil {
+			return err
+		}
+
+		history, err := tx.Bucket(boltRoundsBucket).CreateBucketIfNotExists([]byte(snapshot.Code))
+		if err != nil {
+			return err
+		}
+
+		last := 0
+		for _, round := range rounds {
+			data, err := json.Marshal(round)
+			if err != nil {
+				return err
+			}
+			if err := history.Put(boltRoundKey(round.Nb), data); err != nil {
+				return err
+			}
+			if round.Nb > last {
+				last = round.Nb
+			}
+		}
+
+		// Rounds of a previous match. Deleting while iterating would skip keys
+		stale := make([][]byte, 0)
+		cursor := history.Cursor()
+		for key, _ := cursor.Seek(boltRoundKey(last + 1)); key != nil; key, _ = cursor.Next() {
+			stale = append(stale, append([]byte(nil), key...))
+		}
+		for _, key := range stale {
+			if err := history.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (b *BoltStore) Load(code string) (RoomSnapshot, bool, error) {
+	var snapshot RoomSnapshot
+	found := false
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltRoomsBucket).Get([]byte(code))
+		if data == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return err
+		}
+		found = true
+
+		snapshot.Rounds = make([]RoundResult, 0)
+		history := tx.Bucket(boltRoundsBucket).Bucket([]byte(code))
+		if history == nil {
+			return nil
+		}
+
+		// Keys are big endian, so rounds come in order
+		return history.ForEach(func(key, data []byte) error {
+			var round RoundResult
+			if err := json.Unmarshal(data, &round); err != nil {
+				return err
+			}
+			snapshot.Rounds = append(snapshot.Rounds, round)
+			return nil
+		})
+	})
+	if err != nil {
+		return RoomSnapshot{}, false, err
+	}
+
+	return snapshot, found, nil
+}
+
+func (b *BoltStore) Delete(code string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltRoomsBucket).Delete([]byte(code)); err != nil {
+			return err
+		}
+
+		err := tx.Bucket(boltRoundsBucket).DeleteBucket([]byte(code))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
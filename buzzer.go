@@ -0,0 +1,94 @@
+package main
+
+import (
+	"log"
+
+	"github.com/satori/go.uuid"
+)
+
+const buzzerMode = "buzzer"
+
+// A player buzzed, the countdown is paused while they answer
+type SocketIOBuzzedEvent struct {
+	PlayerName string `json:"player_name"`
+	// Seconds given to answer
+	AnswerTime int `json:"answer_time"`
+}
+
+// The buzzer answered wrong or too late, and can't buzz for a while
+type SocketIOLockedOutEvent struct {
+	PlayerName string `json:"player_name"`
+	// Seconds of the round countdown before they can buzz again
+	Lockout int `json:"lockout"`
+}
+
+// The countdown goes on, anyone not locked out can buzz
+type SocketIOResumedEvent struct {
+	TimeLeft int `json:"time_left"`
+}
+
+func (r *Round) pause() {
+	r.Paused = true
+}
+
+func (r *Round) resume() {
+	r.Paused = false
+}
+
+// Whether the player is still locked out after a wrong answer
+func (r *Round) lockedOut(player *Player) bool {
+	until, ok := r.lockouts[player.ID]
+	return ok && r.TimeLeft > until
+}
+
+// Keeps the player from buzzing until the countdown went down by seconds
+func (r *Round) lockOut(player *Player, seconds int) {
+	if r.lockouts == nil {
+		r.lockouts = make(map[uuid.UUID]int)
+	}
+	r.lockouts[player.ID] = r.TimeLeft - seconds
+}
+
+// Gives the player the exclusive right to answer, pausing the round
+func (r *Room) grantBuzz(player *Player) {
+	round := &r.Game.CurrentRound
+	round.pause()
+	round.buzzer = player
+	round.buzzLeft = r.Game.Settings.BuzzTime
+
+	log.Printf("[%v] %v buzzed", r.Code, player.Name)
+	r.record(MatchLogEntry{Type: logBuzz, PlayerID: player.ID.String(), PlayerName: player.Name, TimeLeft: round.TimeLeft})
+
+	r.broadcast("buzzed", SocketIOBuzzedEvent{PlayerName: player.Name, AnswerTime: round.buzzLeft})
+}
+
+// Ends the buzzer answer window. A right answer ends the round, otherwise the buzzer is locked out
+// and the countdown resumes for everyone else
+func (r *Room) releaseBuzz(right bool) {
+	round := &r.Game.CurrentRound
+	player := round.buzzer
+	round.buzzer = nil
+	round.resume()
+
+	if right {
+		r.revealRound()
+		return
+	}
+
+	lockout := r.Game.Settings.BuzzLockout
+	round.lockOut(player, lockout)
+	r.broadcast("lockedOut", SocketIOLockedOutEvent{PlayerName: player.Name, Lockout: lockout})
+	r.broadcast("resumed", SocketIOResumedEvent{TimeLeft: round.TimeLeft})
+}
+
+// Counts the buzzer answer window down, releasing it once the time is up
+func (r *Room) tickBuzz() {
+	round := &r.Game.CurrentRound
+
+	round.buzzLeft--
+	if round.buzzLeft <= 0 {
+		log.Printf("[%v] %v didn't answer in time", r.Code, round.buzzer.Name)
+		r.record(MatchLogEntry{Type: logBuzzTimeout, PlayerID: round.buzzer.ID.String(), PlayerName: round.buzzer.Name})
+		r.releaseBuzz(false)
+	}
+}
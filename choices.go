@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	freeTextMode       = "free_text"
+	multipleChoiceMode = "multiple_choice"
+)
+
+// Number of answers offered in multiple choice mode
+const choicesCount = 4
+
+type SocketIOChoice struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+}
+
+// Private answer to a 'choose' event, the right option is only revealed by 'response'
+type SocketIOChoiceResultEvent struct {
+	Option  int  `json:"option"`
+	Correct bool `json:"correct"`
+}
+
+// Draws the answers offered for song: itself and distractors from the playlist,
+// preferring songs of the same genre and era
+func pickChoices(song Song, playlist []Song, random Random) []Song {
+	candidates := make([]Song, 0)
+	seen := map[string]bool{choiceKey(song): true}
+
+	// Shuffle first, so that distractors equally close to song come up randomly
+	shuffled := make([]Song, len(playlist))
+	copy(shuffled, playlist)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := random.Intn(i + 1)
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+
+	for _, candidate := range shuffled {
+		if seen[choiceKey(candidate)] {
+			continue
+		}
+		seen[choiceKey(candidate)] = true
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return choiceSimilarity(song, candidates[i]) > choiceSimilarity(song, candidates[j])
+	})
+
+	if len(candidates) > choicesCount-1 {
+		candidates = candidates[:choicesCount-1]
+	}
+
+	// Put the right answer at a random position
+	choices := append(candidates, song)
+	answer := random.Intn(len(choices))
+	choices[answer], choices[len(choices)-1] = choices[len(choices)-1], choices[answer]
+
+	return choices
+}
+
+func choiceKey(song Song) string {
+	return sanitizeString(normalizeTitle(song.Title)) + "|" + sanitizeString(song.Artist.Name)
+}
+
+func choiceSimilarity(song, candidate Song) int {
+	similarity := 0
+
+	if song.Genre != "" && strings.EqualFold(song.Genre, candidate.Genre) {
+		similarity += 2
+	}
+	if song.Year != 0 && candidate.Year != 0 && song.Year/10 == candidate.Year/10 {
+		similarity++
+	}
+
+	return similarity
+}
+
+func newSocketIOChoices(songs []Song) []SocketIOChoice {
+	choices := make([]SocketIOChoice, len(songs))
+	for i, song := range songs {
+		choices[i] = SocketIOChoice{Title: song.Title, Artist: song.Artist.Name}
+	}
+	return choices
+}
+
+// Records the first option chosen by the player. Returns false if they already chose one
+func (r *Round) choose(player *Player, option int) bool {
+	if _, ok := r.chosen[player.ID]; ok {
+		return false
+	}
+
+	if r.chosen == nil {
+		r.chosen = make(map[uuid.UUID]int)
+	}
+	r.chosen[player.ID] = option
+
+	return true
+}
+
+func (r *Round) isRightChoice(option int) bool {
+	return option >= 0 && option < len(r.choices) && r.choices[option].ID == r.Song.ID
+}
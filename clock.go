@@ -0,0 +1,148 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock gives the time to the game, so that tests can drive it manually
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Random is the source of randomness of the game, satisfied by *rand.Rand
+type Random interface {
+	Intn(n int) int
+	Float64() float64
+}
+
+func newRandom(seed int64) Random {
+	return rand.New(rand.NewSource(seed))
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// FakeClock only moves forward when advanced
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticker := &fakeTicker{
+		clock:    c,
+		interval: d,
+		next:     c.now.Add(d),
+		c:        make(chan time.Time),
+		stopped:  make(chan struct{}),
+	}
+	c.tickers = append(c.tickers, ticker)
+
+	return ticker
+}
+
+// Moves the clock forward by d, firing tickers in order. Each tick blocks until it is
+// received, so the clock never runs ahead of the ticker consumers.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		tickers := make([]*fakeTicker, len(c.tickers))
+		copy(tickers, c.tickers)
+		sort.SliceStable(tickers, func(i, j int) bool {
+			return tickers[i].next.Before(tickers[j].next)
+		})
+
+		if len(tickers) == 0 || tickers[0].next.After(target) {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+
+		ticker := tickers[0]
+		c.now = ticker.next
+		ticker.next = ticker.next.Add(ticker.interval)
+		now := c.now
+		c.mu.Unlock()
+
+		select {
+		case ticker.c <- now:
+		case <-ticker.stopped:
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *FakeClock
+	interval time.Duration
+	next     time.Time
+	c        chan time.Time
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stopped)
+	})
+
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			break
+		}
+	}
+}
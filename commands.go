@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"log"
+)
+
+// A command is applied to a room by its loop goroutine, the only one allowed to touch the game
+type command interface {
+	apply(r *Room)
+}
+
+type joinCommand struct {
+	client     Client
+	playerName string
+}
+
+func (c joinCommand) apply(r *Room) {
+	player := newPlayer(c.playerName)
+	r.Game.join(player)
+	r.addClient(c.client, player)
+	r.record(MatchLogEntry{Type: logJoin, PlayerID: player.ID.String(), PlayerName: player.Name})
+
+	c.client.Emit("joined", SocketIOConnectedEvent{Game: newSocketIOGame(&r.Game, r.phase == guessPhase), Player: *player, Token: r.issueToken(player)})
+	r.broadcast("update", newSocketIOUpdateEvent(&r.Game, r.phase == guessPhase))
+	r.publishLeaderBoard()
+	r.snapshot()
+
+	log.Printf("%v joined room %v", player.Name, r.Code)
+}
+
+// Binds the client to the player its session token was issued for
+type reconnectCommand struct {
+	client Client
+	claims SessionClaims
+	token  string
+}
+
+func (c reconnectCommand) apply(r *Room) {
+	player, err := r.claimedPlayer(c.claims)
+	if err != nil {
+		c.client.Emit("error", err.Error())
+		// The room may have been restored for this client only. It closes, keeping its snapshot
+		// for the other players
+		r.closeIfEmpty()
+		return
+	}
+
+	r.addClient(c.client, player)
+
+	c.client.Emit("joined", SocketIOConnectedEvent{Game: newSocketIOGame(&r.Game, r.phase == guessPhase), Player: *player, Token: c.token})
+
+	log.Printf("%v reconnected to room %v", player.Name, r.Code)
+}
+
+// The player quits the game for good
+type leaveCommand struct {
+	client Client
+}
+
+func (c leaveCommand) apply(r *Room) {
+	if player, ok := r.players[c.client.Id()]; ok {
+		r.Game.leave(player)
+		r.record(MatchLogEntry{Type: logLeave, PlayerID: player.ID.String(), PlayerName: player.Name})
+		log.Printf("%v left room %v", player.Name, r.Code)
+	}
+
+	r.removeClient(c.client)
+	r.broadcast("update", newSocketIOUpdateEvent(&r.Game, r.phase == guessPhase))
+	r.publishLeaderBoard()
+
+	// The last player leaving closed the room, deleting its snapshot
+	if len(r.Game.Players) > 0 {
+		r.snapshot()
+	}
+}
+
+// The connection dropped, the player stays in the game so they can reconnect. A room left
+// without client waits emptyRoomGrace seconds for one before closing.
+type disconnectCommand struct {
+	client Client
+}
+
+func (c disconnectCommand) apply(r *Room) {
+	r.removeClient(c.client)
+}
+
+type configureCommand struct {
+	client Client
+	params map[string]interface{}
+}
+
+func (c configureCommand) apply(r *Room) {
+	player, ok := r.players[c.client.Id()]
+	if !ok || !r.isHost(player) {
+		c.client.Emit("error", "Only the host can configure the game")
+		return
+	}
+
+	if r.Game.Started {
+		c.client.Emit("error", "Game already started")
+		return
+	}
+
+	settings, err := r.Game.Settings.apply(c.params)
+	if err != nil {
+		c.client.Emit("error", err.Error())
+		return
+	}
+
+	r.Game.Settings = settings
+	log.Printf("[%v] Settings updated: %+v", r.Code, settings)
+
+	r.broadcast("update", newSocketIOUpdateEvent(&r.Game, r.phase == guessPhase))
+	r.snapshot()
+}
+
+type startCommand struct {
+	client Client
+}
+
+func (c startCommand) apply(r *Room) {
+	player, ok := r.players[c.client.Id()]
+	if !ok || !r.isHost(player) {
+		c.client.Emit("error", "Only the host can start the game")
+		return
+	}
+
+	if r.Game.Started {
+		c.client.Emit("error", "Game already started")
+		return
+	}
+
+	r.startMatch()
+}
+
+// Puts the player in a team, creating it if needed
+type joinTeamCommand struct {
+	client   Client
+	teamName string
+	// Only switch to an existing team
+	existingOnly bool
+}
+
+func (c joinTeamCommand) apply(r *Room) {
+	player, ok := r.players[c.client.Id()]
+	if !ok {
+		c.client.Emit("error", "Join a room first")
+		return
+	}
+
+	if r.Game.Started {
+		c.client.Emit("error", "Teams can't change during a game")
+		return
+	}
+
+	team, err := r.Game.getTeamByName(c.teamName)
+	if err != nil && c.existingOnly {
+		c.client.Emit("error", err.Error())
+		return
+	}
+	if err != nil {
+		team = newTeam(c.teamName)
+	}
+
+	r.Game.joinTeam(player, team)
+	log.Printf("[%v] %v joined team %v", r.Code, player.Name, team.Name)
+
+	r.broadcast("update", newSocketIOUpdateEvent(&r.Game, r.phase == guessPhase))
+	r.publishLeaderBoard()
+	r.snapshot()
+}
+
+type guessCommand struct {
+	client Client
+	guess  string
+}
+
+func (c guessCommand) apply(r *Room) {
+	player, ok := r.players[c.client.Id()]
+	if !ok {
+		c.client.Emit("error", "Join a room first")
+		return
+	}
+
+	if r.phase != guessPhase {
+		c.client.Emit("error", "No round in progress")
+		return
+	}
+
+	if r.Game.Settings.Mode == multipleChoiceMode {
+		c.client.Emit("error", "Answer with the 'choose' event in multiple choice mode")
+		return
+	}
+
+	round := &r.Game.CurrentRound
+	settings := &r.Game.Settings
+
+	buzzer := settings.Mode == buzzerMode
+	if buzzer && round.buzzer != player {
+		c.client.Emit("error", "Buzz first")
+		return
+	}
+
+	evaluator := guessEvaluators[round.Type]
+	evaluation, err := evaluator.evaluate(newGuess(c.guess, round.Song), settings)
+	if err != nil {
+		c.client.Emit("error", err.Error())
+		return
+	}
+
+	if evaluator.singleGuess() && !round.attempt(player) {
+		c.client.Emit("error", "Already guessed")
+		return
+	}
+
+	log.Printf("Guess received from: %v. Guess: %v\n", player.ID, c.guess)
+
+	entry := MatchLogEntry{
+		Type:       logGuess,
+		PlayerID:   player.ID.String(),
+		PlayerName: player.Name,
+		TimeLeft:   round.TimeLeft,
+		Guess:      c.guess,
+		Sanitized:  sanitizeString(c.guess),
+		Similarity: evaluation.Similarity,
+	}
+
+	found := false
+	for _, match := range evaluation.Matches {
+		// A player is only credited once per field and round
+		if r.Game.alreadyFound(player, match.Field) {
+			continue
+		}
+
+		found = true
+		entry.Outcomes = append(entry.Outcomes, match.Field)
+		r.creditField(player, match.Field, match.Points)
+		c.client.Emit("guessResult", SocketIOGuessResultEvent{Outcome: match.Field, Similarity: evaluation.Similarity})
+		r.tellFound(c.client, match)
+		r.broadcast("update", newSocketIOUpdateEvent(&r.Game, r.phase == guessPhase))
+	}
+
+	if !found {
+		outcome := wrongGuess
+		switch {
+		case len(evaluation.Matches) > 0:
+			outcome = alreadyFoundGuess
+		case settings.CloseThreshold > 0 && evaluation.Similarity*100 >= float64(settings.CloseThreshold):
+			outcome = closeGuess
+		}
+
+		entry.Outcomes = append(entry.Outcomes, outcome)
+		c.client.Emit("guessResult", SocketIOGuessResultEvent{Outcome: outcome, Similarity: evaluation.Similarity})
+	}
+
+	// Logged before the buzzer release, which may end the round
+	r.record(entry)
+
+	// The buzzer only gets one try
+	if buzzer {
+		r.releaseBuzz(found)
+	}
+}
+
+// Asks for the exclusive right to answer, in buzzer mode
+type buzzCommand struct {
+	client Client
+}
+
+func (c buzzCommand) apply(r *Room) {
+	player, ok := r.players[c.client.Id()]
+	if !ok {
+		c.client.Emit("error", "Join a room first")
+		return
+	}
+
+	if r.phase != guessPhase || r.Game.Settings.Mode != buzzerMode {
+		c.client.Emit("error", "No buzzer round in progress")
+		return
+	}
+
+	round := &r.Game.CurrentRound
+	if round.buzzer != nil {
+		c.client.Emit("error", "Someone already buzzed")
+		return
+	}
+	if round.lockedOut(player) {
+		c.client.Emit("error", "Locked out")
+		return
+	}
+
+	r.grantBuzz(player)
+}
+
+// Answers a multiple choice round with the index of an option
+type chooseCommand struct {
+	client Client
+	option int
+}
+
+func (c chooseCommand) apply(r *Room) {
+	player, ok := r.players[c.client.Id()]
+	if !ok {
+		c.client.Emit("error", "Join a room first")
+		return
+	}
+
+	if r.phase != guessPhase || r.Game.Settings.Mode != multipleChoiceMode {
+		c.client.Emit("error", "No multiple choice round in progress")
+		return
+	}
+
+	round := &r.Game.CurrentRound
+	if c.option < 0 || c.option >= len(round.choices) {
+		c.client.Emit("error", "Invalid option")
+		return
+	}
+
+	// Only the first submission counts
+	if !round.choose(player, c.option) {
+		c.client.Emit("error", "Already answered")
+		return
+	}
+
+	correct := round.isRightChoice(c.option)
+	log.Printf("Choice received from: %v. Option: %v, correct: %v\n", player.ID, c.option, correct)
+
+	option := c.option
+	r.record(MatchLogEntry{
+		Type:       logChoice,
+		PlayerID:   player.ID.String(),
+		PlayerName: player.Name,
+		TimeLeft:   round.TimeLeft,
+		Option:     &option,
+	})
+
+	if correct {
+		// A teammate may already have found them, with shared team credit
+		if !r.Game.alreadyFound(player, artistField) {
+			r.creditField(player, artistField, r.Game.Settings.ArtistPoints)
+		}
+		if !r.Game.alreadyFound(player, titleField) {
+			r.creditField(player, titleField, r.Game.Settings.TitlePoints)
+		}
+	}
+
+	c.client.Emit("choiceResult", SocketIOChoiceResultEvent{Option: c.option, Correct: correct})
+	if correct {
+		r.broadcast("update", newSocketIOUpdateEvent(&r.Game, r.phase == guessPhase))
+	}
+}
+
+// Replaces the room playlist, replying on result once done
+type importPlaylistCommand struct {
+	claims   SessionClaims
+	playlist Playlist
+	result   chan error
+}
+
+func (c importPlaylistCommand) apply(r *Room) {
+	player, err := r.claimedPlayer(c.claims)
+	if err != nil || !r.isHost(player) {
+		c.result <- errors.New("Only the host can import a playlist")
+		return
+	}
+
+	if r.Game.Started {
+		c.result <- errors.New("Game already started")
+		return
+	}
+
+	r.Source = newStaticSource(c.playlist.Songs)
+	r.Playlist = c.playlist
+	r.imported = true
+	r.snapshot()
+
+	log.Printf("[%v] Playlist imported: %v songs", r.Code, c.playlist.Length)
+
+	c.result <- nil
+}
+
+// Hands the song of the next round back to the room, once resolved
+type songResolvedCommand struct {
+	song Song
+}
+
+func (c songResolvedCommand) apply(r *Room) {
+	r.preparing = false
+	r.nextSong = &c.song
+
+	if r.waitingRound > 0 {
+		r.startWaitingRound()
+	}
+}
+
+// Sent by the room loop every second
+type tickCommand struct{}
+
+func (c tickCommand) apply(r *Room) {
+	r.tickEmpty()
+	if r.closed() {
+		return
+	}
+
+	switch r.phase {
+	case guessPhase:
+		if r.Game.CurrentRound.buzzer != nil {
+			r.tickBuzz()
+			return
+		}
+		if r.Game.CurrentRound.tick() {
+			r.revealRound()
+			return
+		}
+		r.giveHints()
+	case revealPhase:
+		r.revealLeft--
+		if r.revealLeft <= 0 && r.waitingRound == 0 {
+			r.nextRound()
+		}
+	}
+}
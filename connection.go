@@ -0,0 +1,110 @@
+package main
+
+import "log"
+
+// Connection is what a player does through a client, whatever the transport:
+// it tracks the room the client plays in and turns requests into room commands.
+// Its methods are called by a single goroutine per client.
+type Connection struct {
+	client Client
+	// Room the client is currently playing in
+	room *Room
+}
+
+func newConnection(client Client) *Connection {
+	return &Connection{client: client}
+}
+
+// Forwards cmd to the client room, if any
+func (c *Connection) sendToRoom(cmd command) {
+	if c.room == nil {
+		c.client.Emit("error", "Join a room first")
+		return
+	}
+
+	if !c.room.send(cmd) {
+		c.room = nil
+		c.client.Emit("error", "Room closed")
+	}
+}
+
+func (c *Connection) join(roomCode, playerName string) {
+	if c.room != nil {
+		c.room.send(leaveCommand{client: c.client})
+	}
+
+	room, err := rooms.sendOrCreate(roomCode, joinCommand{client: c.client, playerName: playerName})
+	if err != nil {
+		c.room = nil
+		c.client.Emit("error", err.Error())
+		return
+	}
+
+	c.room = room
+}
+
+func (c *Connection) reconnect(token string) {
+	claims, err := rooms.sessions.verify(token)
+	if err != nil {
+		c.client.Emit("error", err.Error())
+		return
+	}
+
+	if c.room != nil {
+		c.room.send(disconnectCommand{client: c.client})
+	}
+
+	// The room may only survive in its snapshot, after a restart
+	room, err := rooms.getOrRestore(claims.RoomCode)
+	if err != nil {
+		c.room = nil
+		c.client.Emit("error", err.Error())
+		return
+	}
+
+	c.room = room
+	c.sendToRoom(reconnectCommand{client: c.client, claims: claims, token: token})
+}
+
+func (c *Connection) configure(params map[string]interface{}) {
+	c.sendToRoom(configureCommand{client: c.client, params: params})
+}
+
+func (c *Connection) start() {
+	c.sendToRoom(startCommand{client: c.client})
+}
+
+// The client plays as the player it joined or reconnected as
+func (c *Connection) buzz() {
+	c.sendToRoom(buzzCommand{client: c.client})
+}
+
+func (c *Connection) choose(option int) {
+	c.sendToRoom(chooseCommand{client: c.client, option: option})
+}
+
+func (c *Connection) guess(guess string) {
+	c.sendToRoom(guessCommand{client: c.client, guess: guess})
+}
+
+func (c *Connection) joinTeam(teamName string, existingOnly bool) {
+	c.sendToRoom(joinTeamCommand{client: c.client, teamName: teamName, existingOnly: existingOnly})
+}
+
+func (c *Connection) leave() {
+	if c.room == nil {
+		return
+	}
+
+	c.room.send(leaveCommand{client: c.client})
+	c.room = nil
+}
+
+func (c *Connection) disconnect() {
+	log.Printf("Client %v disconnected", c.client.Id())
+
+	if c.room != nil {
+		c.room.send(disconnectCommand{client: c.client})
+		c.room = nil
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	deezerAPIURI = "https://api.deezer.com"
+)
+
+// DeezerSource reads tracks from a public Deezer playlist
+type DeezerSource struct {
+	PlaylistURI string
+	client      *http.Client
+}
+
+func newDeezerSource(playlistURI string) *DeezerSource {
+	return &DeezerSource{
+		PlaylistURI: playlistURI,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type deezerError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type deezerPlaylistPage struct {
+	Tracks []deezerTrack `json:"data"`
+	Total  int           `json:"total"`
+	Next   string        `json:"next"`
+	Error  *deezerError  `json:"error"`
+}
+
+type deezerTrack struct {
+	ID         int    `json:"id"`
+	TitleShort string `json:"title_short"`
+	Preview    string `json:"preview"`
+	Rank       int    `json:"rank"`
+	// Only given by the track endpoint, not in playlists
+	ReleaseDate string       `json:"release_date"`
+	Artist      deezerArtist `json:"artist"`
+	Album       deezerAlbum  `json:"album"`
+	Error       *deezerError `json:"error"`
+}
+
+type deezerAlbum struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+type deezerArtist struct {
+	ID      int          `json:"id"`
+	Name    string       `json:"name"`
+	Picture string       `json:"picture"`
+	Error   *deezerError `json:"error"`
+}
+
+func (t *deezerTrack) toSong() Song {
+	return Song{
+		ID:      strconv.Itoa(t.ID),
+		Preview: t.Preview,
+		Title:   t.TitleShort,
+		Album:   t.Album.Title,
+		// Dates are formatted 'YYYY-MM-DD'
+		ReleaseDate: t.ReleaseDate,
+		Year:        parseYear(t.ReleaseDate),
+		Popularity:  t.Rank,
+		Artist: Artist{
+			ID:      strconv.Itoa(t.Artist.ID),
+			Name:    t.Artist.Name,
+			Picture: t.Artist.Picture,
+		},
+	}
+}
+
+func (d *DeezerSource) ListTracks() ([]Song, error) {
+	songs := make([]Song, 0)
+
+	// Deezer paginates playlist tracks, follow 'next' until the last page
+	for uri := d.PlaylistURI; uri != ""; {
+		var page deezerPlaylistPage
+
+		if err := d.get(uri, &page); err != nil {
+			return nil, err
+		}
+		if page.Error != nil {
+			return nil, fmt.Errorf("Deezer error: %v", page.Error.Message)
+		}
+
+		for _, track := range page.Tracks {
+			songs = append(songs, track.toSong())
+		}
+
+		uri = page.Next
+	}
+
+	return songs, nil
+}
+
+// Deezer preview URLs are signed and expire, so fetch a fresh one, along with the release date
+func (d *DeezerSource) Resolve(song Song) (Song, error) {
+	var track deezerTrack
+
+	if err := d.get(fmt.Sprintf("%v/track/%v", deezerAPIURI, song.ID), &track); err != nil {
+		return song, err
+	}
+	if track.Error != nil {
+		return song, fmt.Errorf("Deezer error: %v", track.Error.Message)
+	}
+
+	song.Preview = track.Preview
+	if track.ReleaseDate != "" {
+		song.ReleaseDate = track.ReleaseDate
+		song.Year = parseYear(track.ReleaseDate)
+	}
+	if track.Album.Title != "" {
+		song.Album = track.Album.Title
+	}
+
+	return song, nil
+}
+
+func (d *DeezerSource) ArtistPicture(artist Artist) (string, error) {
+	if artist.Picture != "" {
+		return artist.Picture, nil
+	}
+
+	var deezerArtist deezerArtist
+
+	if err := d.get(fmt.Sprintf("%v/artist/%v", deezerAPIURI, artist.ID), &deezerArtist); err != nil {
+		return "", err
+	}
+	if deezerArtist.Error != nil {
+		return "", fmt.Errorf("Deezer error: %v", deezerArtist.Error.Message)
+	}
+
+	return deezerArtist.Picture, nil
+}
+
+func (d *DeezerSource) get(uri string, target interface{}) error {
+	response, err := d.client.Get(uri)
+	if err != nil {
+		return fmt.Errorf("Can't reach Deezer. Err: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Deezer responded with status %v", response.StatusCode)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+		return fmt.Errorf("Couldn't decode Deezer JSON. Err: %v", err)
+	}
+
+	return nil
+}
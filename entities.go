@@ -2,8 +2,6 @@ package main
 
 import (
 	"errors"
-	"github.com/hbakhtiyor/strsim"
-	"math/rand"
 	"sort"
 	"time"
 
@@ -11,12 +9,17 @@ import (
 )
 
 type SocketIOConnectedEvent struct {
-	Game   Game   `json:"game_status"`
-	Player Player `json:"player"`
+	Game   SocketIOGame `json:"game_status"`
+	Player Player       `json:"player"`
+	// Signed token to get back in the room with
+	Token string `json:"token"`
 }
 
 type SocketIOSongStartedEvent struct {
 	SongPreviewURI string `json:"preview_uri"`
+	RoundType      string `json:"round_type"`
+	// Answers to choose from, in multiple choice mode
+	Choices []SocketIOChoice `json:"choices,omitempty"`
 }
 
 type SocketIOArtistGuessedEvent struct {
@@ -28,38 +31,173 @@ type SocketIOSongGuessedEvent struct {
 	SongTitle string `json:"song_title"`
 }
 
+// Outcomes of a guess, besides the fields it found
+const (
+	wrongGuess        = "wrong"
+	closeGuess        = "close"
+	alreadyFoundGuess = "already_found"
+)
+
+// Private feedback on a guess. Outcome is wrongGuess, closeGuess, alreadyFoundGuess,
+// or the field found, once per field.
+type SocketIOGuessResultEvent struct {
+	Outcome string `json:"outcome"`
+	// Best similarity to an answer not found, from 0 to 1
+	Similarity float64 `json:"similarity"`
+}
+
+type SocketIOYearGuessedEvent struct {
+	Year   int `json:"year"`
+	Points int `json:"points"`
+}
+
+// Tells who found a field, without revealing it
+type SocketIOPlayerFoundEvent struct {
+	PlayerName string `json:"player_name"`
+	Field      string `json:"field"`
+	Rank       int    `json:"rank"`
+}
+
 type SocketIOUpdateEvent struct {
-	Game Game `json:"game"`
+	Game    SocketIOGame           `json:"game"`
+	Players []SocketIOPlayerStatus `json:"players"`
 }
 
-type SocketIOResponseEvent struct {
-	Song Song `json:"song"`
+// Player as the others see them, without the id that would let them play on their behalf
+type SocketIOPlayer struct {
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+	TeamID string `json:"team_id"`
 }
 
-type Playlist struct {
-	Songs  []Song `json:"data"`
-	Length int    `json:"total"`
-	// TODO: Think about other struct, 'next' is not meaningful here
-	Next string `json:"next"`
+func newSocketIOPlayer(player *Player) SocketIOPlayer {
+	return SocketIOPlayer{Name: player.Name, Score: player.Score, TeamID: player.TeamID}
+}
+
+func newSocketIOPlayers(players []*Player) []SocketIOPlayer {
+	socketIOPlayers := make([]SocketIOPlayer, len(players))
+	for i, player := range players {
+		socketIOPlayers[i] = newSocketIOPlayer(player)
+	}
+
+	return socketIOPlayers
+}
+
+// Game status sent to players, stripped of player ids
+type SocketIOGame struct {
+	Players      []SocketIOPlayer
+	CurrentRound SocketIORound
+	SongsPlayed  []Song
+	Settings     GameSettings
+	Started      bool
+	Teams        []*Team
 }
 
-func (p *Playlist) getRandomSong() Song {
-	// Generate non-negative random number, between 0 and playlist's length + 1
-	rand.Seed(time.Now().UnixNano())
-	idx := rand.Intn(p.Length - 1)
+type SocketIORound struct {
+	Nb   int
+	Type string
+	// Nil while players guess, the preview being all they get of the song
+	Song           *Song
+	SongPreviewURI string
+	TimeLeft       int
+	StartedAt      time.Time
+	Scores         []SocketIORoundScore
+	Hints          int
+	Paused         bool
+}
+
+type SocketIORoundScore struct {
+	PlayerName string       `json:"player_name"`
+	Total      int          `json:"total"`
+	Awards     []ScoreAward `json:"awards"`
+}
+
+func newSocketIORoundScores(scores []*RoundScore) []SocketIORoundScore {
+	socketIOScores := make([]SocketIORoundScore, len(scores))
+	for i, score := range scores {
+		socketIOScores[i] = SocketIORoundScore{PlayerName: score.PlayerName, Total: score.Total, Awards: score.Awards}
+	}
 
-	// Use random generated index to access a song in playlist
-	return p.Songs[idx]
+	return socketIOScores
+}
+
+// The current song is left out while guessing is true
+func newSocketIOGame(game *Game, guessing bool) SocketIOGame {
+	round := game.CurrentRound
+
+	currentRound := SocketIORound{
+		Nb:             round.Nb,
+		Type:           round.Type,
+		SongPreviewURI: round.Song.Preview,
+		TimeLeft:       round.TimeLeft,
+		StartedAt:      round.StartedAt,
+		Scores:         newSocketIORoundScores(round.Scores),
+		Hints:          round.Hints,
+		Paused:         round.Paused,
+	}
+	if !guessing {
+		currentRound.Song = &round.Song
+	}
+
+	return SocketIOGame{
+		Players:      newSocketIOPlayers(game.Players),
+		CurrentRound: currentRound,
+		SongsPlayed:  game.SongsPlayed,
+		Settings:     game.Settings,
+		Started:      game.Started,
+		Teams:        game.Teams,
+	}
+}
+
+// Player state for the scoreboard, with the fields found during the current round
+type SocketIOPlayerStatus struct {
+	SocketIOPlayer
+	FoundArtist bool `json:"found_artist"`
+	FoundTitle  bool `json:"found_title"`
+	FoundYear   bool `json:"found_year"`
+}
+
+func newSocketIOUpdateEvent(game *Game, guessing bool) SocketIOUpdateEvent {
+	players := make([]SocketIOPlayerStatus, len(game.Players))
+
+	for i, player := range game.Players {
+		players[i] = SocketIOPlayerStatus{
+			SocketIOPlayer: newSocketIOPlayer(player),
+			FoundArtist:    game.CurrentRound.hasFound(player, artistField),
+			FoundTitle:     game.CurrentRound.hasFound(player, titleField),
+			FoundYear:      game.CurrentRound.hasFound(player, yearField),
+		}
+	}
+
+	return SocketIOUpdateEvent{Game: newSocketIOGame(game, guessing), Players: players}
+}
+
+type SocketIOResponseEvent struct {
+	Song   Song                 `json:"song"`
+	Scores []SocketIORoundScore `json:"scores"`
+}
+
+type Playlist struct {
+	Songs  []Song `json:"songs"`
+	Length int    `json:"length"`
 }
 
 type Song struct {
-	Id      int    `json:"id"`
+	ID      string `json:"id"`
 	Preview string `json:"preview"`
 	Artist  Artist `json:"artist"`
-	Title   string `json:"title_short"`
+	Title   string `json:"title"`
+	Album   string `json:"album"`
+	Year    int    `json:"year"`
+	Genre   string `json:"genre"`
+	// 'YYYY-MM-DD', when known
+	ReleaseDate string `json:"release_date"`
+	// Relative to the other songs of the catalog, 0 when unknown
+	Popularity int `json:"popularity"`
 }
 
 type Artist struct {
+	ID      string `json:"id"`
 	Name    string `json:"name"`
 	Picture string `json:"picture"`
 }
@@ -68,18 +206,49 @@ type Game struct {
 	Players      []*Player
 	CurrentRound Round
 	SongsPlayed  []Song
+	// Results of the rounds played so far in the match
+	RoundsPlayed []RoundResult
+	Settings     GameSettings
+	Started      bool
+	Teams        []*Team
+	clock        Clock
+	random       Random
+	// Ids of the songs played during the previous matches, oldest first
+	recentMatches [][]string
 }
 
-func newGame(players []*Player) Game {
-	return Game{Players: players, CurrentRound: Round{}}
+func newGame(players []*Player, clock Clock, random Random) Game {
+	return Game{
+		Players:      players,
+		CurrentRound: Round{},
+		Settings:     defaultGameSettings(),
+		Teams:        make([]*Team, 0),
+		clock:        clock,
+		random:       random,
+	}
 }
 
+// Keeping more matches would be useless, as settings can't avoid more of them
+const maxRecentMatches = 10
+
 func (g *Game) restart() {
+	playedIDs := make([]string, len(g.SongsPlayed))
+	for i, song := range g.SongsPlayed {
+		playedIDs[i] = song.ID
+	}
+	g.recentMatches = append(g.recentMatches, playedIDs)
+	if len(g.recentMatches) > maxRecentMatches {
+		g.recentMatches = g.recentMatches[1:]
+	}
+
 	g.CurrentRound = Round{}
+	g.Started = false
 	g.SongsPlayed = make([]Song, 0)
+	g.RoundsPlayed = make([]RoundResult, 0)
 	for _, v := range g.Players {
 		v.resetScore()
 	}
+	g.updateTeams()
 }
 
 func (g *Game) join(player *Player) {
@@ -96,6 +265,7 @@ func (g *Game) leave(player *Player) {
 	}
 
 	g.Players = tempPlayers
+	g.updateTeams()
 }
 
 func (g *Game) getLeaderBoard() *[]*Player {
@@ -125,30 +295,130 @@ func (g *Game) getPlayerByID(id string) (*Player, error) {
 	return foundPlayer, nil
 }
 
-func (g *Game) addSongToHistory(song *Song) {
-	g.SongsPlayed = append(g.SongsPlayed, *song)
+// Points scored during a round of the match
+type RoundResult struct {
+	Nb     int           `json:"nb"`
+	Type   string        `json:"type"`
+	SongID string        `json:"song_id"`
+	Scores []*RoundScore `json:"scores"`
+}
+
+func (g *Game) addRoundToHistory(round *Round) {
+	g.SongsPlayed = append(g.SongsPlayed, round.Song)
+	g.RoundsPlayed = append(g.RoundsPlayed, RoundResult{Nb: round.Nb, Type: round.Type, SongID: round.Song.ID, Scores: round.Scores})
 }
 
+const (
+	artistField = "artist"
+	titleField  = "title"
+)
+
 type Round struct {
-	Nb       int
-	Song     Song
-	TimeLeft int
+	Nb int
+	// songRound or yearRound
+	Type      string
+	Song      Song
+	TimeLeft  int
+	StartedAt time.Time
+	Scores    []*RoundScore
+	// Fields of the song each player found, by player id
+	Found map[uuid.UUID]map[string]bool
+	// Number of hints given
+	Hints int
+	// Order the letters of each field are revealed in by hints
+	hintOrders map[string][]int
+	// Answers offered in multiple choice mode, and the option each player chose
+	choices []Song
+	chosen  map[uuid.UUID]int
+	// The countdown is paused while a player answers after buzzing
+	Paused bool
+	// Player allowed to answer in buzzer mode, and the seconds left to do so
+	buzzer   *Player
+	buzzLeft int
+	// TimeLeft under which a player who answered wrong can buzz again, by player id
+	lockouts map[uuid.UUID]int
+	// Players who guessed, for rounds allowing a single guess
+	guessed map[uuid.UUID]bool
 }
 
-func (r *Round) countdown() {
-	for range time.Tick(1 * time.Second) {
-		r.TimeLeft--
+func (r *Round) hasFound(player *Player, field string) bool {
+	return r.Found[player.ID][field]
+}
+
+// Number of players credited for finding field
+func (r *Round) foundCount(field string) int {
+	count := 0
+	for _, score := range r.Scores {
+		for _, award := range score.Awards {
+			if award.Field == field {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func (r *Round) markFound(player *Player, field string) {
+	if r.Found == nil {
+		r.Found = make(map[uuid.UUID]map[string]bool)
+	}
+	if r.Found[player.ID] == nil {
+		r.Found[player.ID] = make(map[string]bool)
+	}
+
+	r.Found[player.ID][field] = true
+}
+
+// Records the player guess. Returns false if they already guessed
+func (r *Round) attempt(player *Player) bool {
+	if r.guessed[player.ID] {
+		return false
+	}
 
-		if r.TimeLeft == 0 {
-			break
+	if r.guessed == nil {
+		r.guessed = make(map[uuid.UUID]bool)
+	}
+	r.guessed[player.ID] = true
+
+	return true
+}
+
+func (r *Round) recordAward(player *Player, award ScoreAward) {
+	for _, score := range r.Scores {
+		if score.PlayerID == player.ID {
+			score.Awards = append(score.Awards, award)
+			score.Total += award.total()
+			return
 		}
 	}
+
+	r.Scores = append(r.Scores, &RoundScore{
+		PlayerID:   player.ID,
+		PlayerName: player.Name,
+		Total:      award.total(),
+		Awards:     []ScoreAward{award},
+	})
+}
+
+// Counts a second down unless paused, returns true once the time is up
+func (r *Round) tick() bool {
+	if r.Paused {
+		return false
+	}
+
+	if r.TimeLeft > 0 {
+		r.TimeLeft--
+	}
+
+	return r.TimeLeft == 0
 }
 
 type Player struct {
 	ID    uuid.UUID `json:"id"`
 	Name  string    `json:"name"`
 	Score int       `json:"score"`
+	// Empty when the player is in no team
+	TeamID string `json:"team_id"`
 }
 
 func newPlayer(name string) *Player {
@@ -168,21 +438,44 @@ func (p *Player) increaseScore(points int) {
 }
 
 type Guess struct {
-	Guess       string
+	Guess string
+	// As typed by the player
+	Raw         string
 	CurrentSong Song
 }
 
 func newGuess(guess string, currentSong Song) *Guess {
 	return &Guess{
 		Guess:       sanitizeString(guess),
+		Raw:         guess,
 		CurrentSong: currentSong,
 	}
 }
 
-func (g *Guess) songGuessed() bool {
-	return strsim.Compare(g.Guess, sanitizeString(g.CurrentSong.Title)) > 0.8
+func (g *Guess) songGuessed(match answerMatcher) bool {
+	return g.matchesAny(g.answers(titleField), match)
+}
+
+// Any credited artist counts
+func (g *Guess) artistGuessed(match answerMatcher) bool {
+	return g.matchesAny(g.answers(artistField), match)
+}
+
+// Returns the accepted answers for field, aliases included
+func (g *Guess) answers(field string) []string {
+	if field == artistField {
+		return aliases.expand(field, artistAnswers(g.CurrentSong))
+	}
+	return aliases.expand(field, titleAnswers(g.CurrentSong))
 }
 
-func (g *Guess) artistGuessed() bool {
-	return strsim.Compare(g.Guess, sanitizeString(g.CurrentSong.Artist.Name)) > 0.8
+func (g *Guess) matchesAny(answers []string, match answerMatcher) bool {
+	stripped := stripArticle(g.Raw)
+
+	for _, answer := range answers {
+		if match(g.Raw, answer) || match(stripped, answer) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Types of round, each with its own guess evaluator
+const (
+	// Guess the artist and title
+	songRound = "song"
+	// Guess the release year
+	yearRound = "year"
+)
+
+const yearField = "year"
+
+// A field of the song found by a guess, and the points it is worth before bonuses
+type GuessMatch struct {
+	Field  string
+	Points int
+}
+
+// What a guess got right
+type GuessEvaluation struct {
+	Matches []GuessMatch
+	// Best similarity to the answers of the fields not matched, from 0 to 1
+	Similarity float64
+}
+
+// GuessEvaluator finds what a guess got right, for a type of round
+type GuessEvaluator interface {
+	// Evaluates guess, or returns an error if it isn't a valid answer at all
+	evaluate(guess *Guess, settings *GameSettings) (GuessEvaluation, error)
+	// Whether players only get one guess per round, for answers that could be enumerated
+	singleGuess() bool
+}
+
+var guessEvaluators = map[string]GuessEvaluator{
+	songRound: songEvaluator{},
+	yearRound: yearEvaluator{},
+}
+
+type songEvaluator struct{}
+
+func (songEvaluator) evaluate(guess *Guess, settings *GameSettings) (GuessEvaluation, error) {
+	evaluation := GuessEvaluation{Matches: make([]GuessMatch, 0)}
+	match := answerMatchers[settings.Matching]
+
+	fields := []struct {
+		field   string
+		guessed func(answerMatcher) bool
+		points  int
+	}{
+		{artistField, guess.artistGuessed, settings.ArtistPoints},
+		{titleField, guess.songGuessed, settings.TitlePoints},
+	}
+
+	for _, f := range fields {
+		if f.guessed(match) {
+			evaluation.Matches = append(evaluation.Matches, GuessMatch{Field: f.field, Points: f.points})
+			continue
+		}
+
+		similarity := guess.logNearMiss(f.field)
+		if similarity > evaluation.Similarity {
+			evaluation.Similarity = similarity
+		}
+	}
+
+	return evaluation, nil
+}
+
+func (songEvaluator) singleGuess() bool {
+	return false
+}
+
+// Share of the year points earned, in percent, by maximum distance to the release year
+var yearBrackets = []struct {
+	distance int
+	percent  int
+}{
+	{0, 100},
+	{1, 50},
+	{5, 20},
+}
+
+type yearEvaluator struct{}
+
+func (yearEvaluator) evaluate(guess *Guess, settings *GameSettings) (GuessEvaluation, error) {
+	// Years can be written in words too
+	year, err := strconv.Atoi(sanitizeString(normalizeNumbers(guess.Raw)))
+	if err != nil {
+		return GuessEvaluation{}, errors.New("Guess a year")
+	}
+
+	distance := year - guess.CurrentSong.Year
+	if distance < 0 {
+		distance = -distance
+	}
+
+	for _, bracket := range yearBrackets {
+		if distance <= bracket.distance {
+			points := settings.YearPoints * bracket.percent / 100
+			return GuessEvaluation{Matches: []GuessMatch{{Field: yearField, Points: points}}}, nil
+		}
+	}
+
+	return GuessEvaluation{Matches: []GuessMatch{}}, nil
+}
+
+func (yearEvaluator) singleGuess() bool {
+	return true
+}
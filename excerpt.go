@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Length of the excerpts served for local songs, in seconds
+const excerptLength = 30.0
+
+// Cuts an excerpt of length seconds out of an audio file, without re-encoding it
+func extractExcerpt(data []byte, extension string, length float64) ([]byte, error) {
+	switch extension {
+	case ".mp3":
+		return mp3Excerpt(data, length)
+	case ".ogg", ".oga", ".opus":
+		return oggExcerpt(data, length)
+	case ".flac":
+		return flacExcerpt(data, length)
+	}
+
+	return nil, fmt.Errorf("Unsupported audio format '%v'", extension)
+}
+
+// Excerpts start a third into the song, where the chorus usually is
+func excerptStart(duration, length float64) float64 {
+	if duration <= length {
+		return 0
+	}
+
+	start := duration / 3
+	if start+length > duration {
+		start = duration - length
+	}
+
+	return start
+}
+
+var (
+	mpeg1Layer3Bitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2Layer3Bitrates = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mpegSampleRates     = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// Parses an MPEG audio layer III frame header, returning the frame size in bytes and its duration in seconds
+func parseMP3FrameHeader(h []byte) (int, float64, bool) {
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return 0, 0, false
+	}
+
+	version := (h[1] >> 3) & 0x03
+	layer := (h[1] >> 1) & 0x03
+	bitrateIndex := h[2] >> 4
+	sampleRateIndex := (h[2] >> 2) & 0x03
+	padding := int((h[2] >> 1) & 0x01)
+
+	sampleRates, ok := mpegSampleRates[version]
+	if !ok || layer != 1 || sampleRateIndex == 3 {
+		return 0, 0, false
+	}
+	sampleRate := sampleRates[sampleRateIndex]
+
+	bitrate, samples, coefficient := mpeg1Layer3Bitrates[bitrateIndex], 1152, 144
+	if version != 3 {
+		bitrate, samples, coefficient = mpeg2Layer3Bitrates[bitrateIndex], 576, 72
+	}
+	if bitrate == 0 {
+		return 0, 0, false
+	}
+
+	size := coefficient*bitrate*1000/sampleRate + padding
+	return size, float64(samples) / float64(sampleRate), true
+}
+
+func mp3Excerpt(data []byte, length float64) ([]byte, error) {
+	// Skip ID3v2 tag
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		tagSize := 10 + syncsafeInt(data[6:10])
+		if data[5]&0x10 != 0 {
+			// Footer
+			tagSize += 10
+		}
+		if tagSize > len(data) {
+			return nil, errors.New("Invalid ID3v2 tag")
+		}
+		data = data[tagSize:]
+	}
+
+	type frame struct {
+		offset   int
+		size     int
+		duration float64
+	}
+
+	frames := make([]frame, 0)
+	total := 0.0
+	for i := 0; i+4 <= len(data); {
+		size, duration, ok := parseMP3FrameHeader(data[i : i+4])
+		if !ok || i+size > len(data) {
+			// Resync on the next byte
+			i++
+			continue
+		}
+
+		frames = append(frames, frame{offset: i, size: size, duration: duration})
+		total += duration
+		i += size
+	}
+
+	if len(frames) == 0 {
+		return nil, errors.New("No MP3 frame found")
+	}
+
+	start := excerptStart(total, length)
+	var excerpt bytes.Buffer
+	elapsed := 0.0
+	for _, f := range frames {
+		if elapsed >= start && elapsed < start+length {
+			excerpt.Write(data[f.offset : f.offset+f.size])
+		}
+		elapsed += f.duration
+	}
+
+	return excerpt.Bytes(), nil
+}
+
+func oggExcerpt(data []byte, length float64) ([]byte, error) {
+	type page struct {
+		offset  int
+		size    int
+		granule int64
+		// Number of packets ending in the page
+		packets int
+		body    []byte
+	}
+
+	pages := make([]page, 0)
+	for i := 0; i < len(data); {
+		if i+27 > len(data) || string(data[i:i+4]) != "OggS" {
+			return nil, errors.New("Invalid Ogg page")
+		}
+
+		segmentsCount := int(data[i+26])
+		if i+27+segmentsCount > len(data) {
+			return nil, errors.New("Invalid Ogg page")
+		}
+		segments := data[i+27 : i+27+segmentsCount]
+
+		bodySize, packets := 0, 0
+		for _, segmentSize := range segments {
+			bodySize += int(segmentSize)
+			if segmentSize < 255 {
+				packets++
+			}
+		}
+
+		size := 27 + segmentsCount + bodySize
+		if i+size > len(data) {
+			return nil, errors.New("Truncated Ogg page")
+		}
+
+		pages = append(pages, page{
+			offset:  i,
+			size:    size,
+			granule: int64(binary.LittleEndian.Uint64(data[i+6 : i+14])),
+			packets: packets,
+			body:    data[i+27+segmentsCount : i+size],
+		})
+		i += size
+	}
+
+	if len(pages) == 0 {
+		return nil, errors.New("No Ogg page found")
+	}
+
+	// Codec headers must be kept for the excerpt to be decodable
+	var headersCount int
+	var sampleRate float64
+	identification := pages[0].body
+	switch {
+	case bytes.HasPrefix(identification, []byte("\x01vorbis")) && len(identification) >= 16:
+		headersCount = 3
+		sampleRate = float64(binary.LittleEndian.Uint32(identification[12:16]))
+	case bytes.HasPrefix(identification, []byte("OpusHead")):
+		headersCount = 2
+		// Opus granule positions are always expressed at 48kHz
+		sampleRate = 48000
+	default:
+		return nil, errors.New("Unknown Ogg codec")
+	}
+	if sampleRate == 0 {
+		return nil, errors.New("Invalid Ogg sample rate")
+	}
+
+	var excerpt bytes.Buffer
+	packets := 0
+	audioPages := pages
+	for len(audioPages) > 0 && packets < headersCount {
+		excerpt.Write(data[audioPages[0].offset : audioPages[0].offset+audioPages[0].size])
+		packets += audioPages[0].packets
+		audioPages = audioPages[1:]
+	}
+
+	duration := float64(pages[len(pages)-1].granule) / sampleRate
+	start := excerptStart(duration, length)
+	startGranule := int64(start * sampleRate)
+	endGranule := int64((start + length) * sampleRate)
+
+	previous := int64(0)
+	for _, p := range audioPages {
+		// -1 means no packet ends in the page
+		granule := p.granule
+		if granule < 0 {
+			granule = previous
+		}
+
+		if granule > startGranule && previous < endGranule {
+			excerpt.Write(data[p.offset : p.offset+p.size])
+		}
+		previous = granule
+	}
+
+	return excerpt.Bytes(), nil
+}
+
+func flacExcerpt(data []byte, length float64) ([]byte, error) {
+	if len(data) < 4 || string(data[:4]) != "fLaC" {
+		return nil, errors.New("Not a FLAC file")
+	}
+
+	var streamInfo []byte
+	offset := 4
+	for {
+		if offset+4 > len(data) {
+			return nil, errors.New("Truncated FLAC metadata")
+		}
+
+		last := data[offset]&0x80 != 0
+		blockType := data[offset] & 0x7f
+		size := int(data[offset+1])<<16 | int(data[offset+2])<<8 | int(data[offset+3])
+		if offset+4+size > len(data) {
+			return nil, errors.New("Truncated FLAC metadata")
+		}
+
+		if blockType == 0 && size >= 34 {
+			streamInfo = make([]byte, size)
+			copy(streamInfo, data[offset+4:offset+4+size])
+		}
+
+		offset += 4 + size
+		if last {
+			break
+		}
+	}
+
+	if streamInfo == nil {
+		return nil, errors.New("No FLAC STREAMINFO block")
+	}
+
+	sampleRate := int(streamInfo[10])<<12 | int(streamInfo[11])<<4 | int(streamInfo[12])>>4
+	totalSamples := int64(streamInfo[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(streamInfo[14:18]))
+	if sampleRate == 0 || totalSamples == 0 {
+		return nil, errors.New("Unknown FLAC duration")
+	}
+
+	// The excerpt length differs from the original one: mark total samples and MD5 as unknown
+	streamInfo[13] &= 0xf0
+	for i := 14; i < 34; i++ {
+		streamInfo[i] = 0
+	}
+
+	var excerpt bytes.Buffer
+	excerpt.WriteString("fLaC")
+	// Only keep STREAMINFO, as last metadata block
+	excerpt.Write([]byte{0x80, byte(len(streamInfo) >> 16), byte(len(streamInfo) >> 8), byte(len(streamInfo))})
+	excerpt.Write(streamInfo)
+
+	// Frames are variable sized, estimate positions from the average bitrate and align on frame sync codes
+	audio := data[offset:]
+	duration := float64(totalSamples) / float64(sampleRate)
+	start := excerptStart(duration, length)
+	startOffset := nextFLACFrame(audio, int(float64(len(audio))*start/duration))
+	endOffset := nextFLACFrame(audio, int(float64(len(audio))*(start+length)/duration))
+
+	excerpt.Write(audio[startOffset:endOffset])
+
+	return excerpt.Bytes(), nil
+}
+
+func nextFLACFrame(audio []byte, from int) int {
+	for i := from; i+1 < len(audio); i++ {
+		if audio[i] == 0xff && audio[i+1]&0xfe == 0xf8 {
+			return i
+		}
+	}
+	return len(audio)
+}
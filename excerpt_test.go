@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestExcerptStart(t *testing.T) {
+	tests := []struct {
+		duration, length, want float64
+	}{
+		{20, 30, 0},
+		{30, 30, 0},
+		{180, 30, 60},
+		// A third in would overrun the end
+		{40, 30, 10},
+	}
+
+	for _, test := range tests {
+		if got := excerptStart(test.duration, test.length); got != test.want {
+			t.Errorf("excerptStart(%v, %v) = %v, expected %v", test.duration, test.length, got, test.want)
+		}
+	}
+}
+
+func TestParseMP3FrameHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []byte
+		size     int
+		duration float64
+		ok       bool
+	}{
+		{"MPEG 1, 128kbps, 44.1kHz", []byte{0xff, 0xfb, 0x90, 0x00}, 417, 1152.0 / 44100, true},
+		{"Padded", []byte{0xff, 0xfb, 0x92, 0x00}, 418, 1152.0 / 44100, true},
+		{"MPEG 1, 320kbps, 48kHz", []byte{0xff, 0xfb, 0xe4, 0x00}, 960, 1152.0 / 48000, true},
+		{"MPEG 2, 80kbps, 22.05kHz", []byte{0xff, 0xf3, 0x90, 0x00}, 261, 576.0 / 22050, true},
+		{"MPEG 2.5, 8kbps, 8kHz", []byte{0xff, 0xe3, 0x18, 0x00}, 72, 576.0 / 8000, true},
+		{"No sync", []byte{0x49, 0x44, 0x33, 0x03}, 0, 0, false},
+		{"Layer II", []byte{0xff, 0xfd, 0x90, 0x00}, 0, 0, false},
+		{"Reserved version", []byte{0xff, 0xeb, 0x90, 0x00}, 0, 0, false},
+		{"Free bitrate", []byte{0xff, 0xfb, 0x00, 0x00}, 0, 0, false},
+		{"Bad bitrate", []byte{0xff, 0xfb, 0xf0, 0x00}, 0, 0, false},
+		{"Reserved sample rate", []byte{0xff, 0xfb, 0x9c, 0x00}, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		size, duration, ok := parseMP3FrameHeader(test.header)
+		if ok != test.ok || size != test.size || math.Abs(duration-test.duration) > 1e-9 {
+			t.Errorf("%v: got (%v, %v, %v), expected (%v, %v, %v)", test.name, size, duration, ok, test.size, test.duration, test.ok)
+		}
+	}
+}
+
+// Builds n MPEG 1 frames of 128kbps at 44.1kHz, numbered in their first byte of data
+func mp3Frames(n int) []byte {
+	var data bytes.Buffer
+	for i := 0; i < n; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00, byte(i)})
+		data.Write(frame)
+	}
+	return data.Bytes()
+}
+
+func TestMP3Excerpt(t *testing.T) {
+	// 100 frames last 2.6s: a 1s excerpt covers the frames starting from 0.87s to 1.87s
+	first, last := 34, 71
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Frames only", mp3Frames(100)},
+		{"After an ID3v2 tag", append(id3Tag(3, [2]string{"TIT2", "\x00Title"}), mp3Frames(100)...)},
+		{"After garbage", append([]byte{0x00, 0xff, 0x12}, mp3Frames(100)...)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			excerpt, err := extractExcerpt(test.data, ".mp3", 1)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(excerpt) != (last-first+1)*417 {
+				t.Fatalf("Excerpt of %v bytes, expected %v frames", len(excerpt), last-first+1)
+			}
+			if excerpt[4] != byte(first) || excerpt[len(excerpt)-417+4] != byte(last) {
+				t.Errorf("Excerpt from frame %v to %v, expected %v to %v", excerpt[4], excerpt[len(excerpt)-417+4], first, last)
+			}
+		})
+	}
+
+	if _, err := extractExcerpt([]byte("not an mp3 file"), ".mp3", 1); err == nil {
+		t.Error("Expected an error without frame")
+	}
+}
+
+// Builds a Vorbis identification header with the given sample rate
+func vorbisIdentification(sampleRate uint32) []byte {
+	header := make([]byte, 30)
+	copy(header, "\x01vorbis")
+	binary.LittleEndian.PutUint32(header[12:16], sampleRate)
+	return header
+}
+
+func TestOggExcerpt(t *testing.T) {
+	var data []byte
+	data = append(data, oggPage(0, vorbisIdentification(1000))...)
+	data = append(data, oggPage(0, append([]byte("\x03vorbis"), vorbisComment()...), []byte("\x05vorbis setup"))...)
+	// 60 pages of 1s of audio, numbered in their packet
+	for i := 1; i <= 60; i++ {
+		data = append(data, oggPage(int64(i*1000), []byte{byte(i)})...)
+	}
+
+	excerpt, err := extractExcerpt(data, ".ogg", 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	packets, err := readOggPackets(bytes.NewReader(excerpt), 13)
+	if err != nil {
+		t.Fatalf("Can't read excerpt: %v", err)
+	}
+	if !bytes.Equal(packets[0], vorbisIdentification(1000)) || !bytes.HasPrefix(packets[1], []byte("\x03vorbis")) {
+		t.Error("Codec headers not kept")
+	}
+	// The excerpt starts at 20s, in the page ending at 21s
+	for i, packet := range packets[3:] {
+		if want := byte(21 + i); packet[0] != want {
+			t.Errorf("Audio packet %v is page %v, expected %v", i, packet[0], want)
+		}
+	}
+	if _, err := readOggPackets(bytes.NewReader(excerpt), 14); err == nil {
+		t.Error("Excerpt longer than 10 pages")
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Unknown codec", oggPage(0, []byte("\x80theora"))},
+		{"No sample rate", oggPage(0, vorbisIdentification(0))},
+		{"Truncated page", oggPage(0, vorbisIdentification(1000))[:40]},
+		{"Not Ogg", []byte("fLaC")},
+	}
+	for _, test := range tests {
+		if _, err := extractExcerpt(test.data, ".ogg", 10); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+// Builds a STREAMINFO block content
+func flacStreamInfo(sampleRate int, totalSamples int64) []byte {
+	info := make([]byte, 34)
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4)
+	info[12] = byte(sampleRate<<4) | 0x01
+	info[13] = byte(totalSamples>>32) & 0x0f
+	binary.BigEndian.PutUint32(info[14:18], uint32(totalSamples))
+	// MD5
+	copy(info[18:], bytes.Repeat([]byte{0xaa}, 16))
+	return info
+}
+
+func TestFLACExcerpt(t *testing.T) {
+	// 60 frames of 1s, each starting with a sync code
+	var audio []byte
+	for i := 0; i < 60; i++ {
+		frame := make([]byte, 100)
+		copy(frame, []byte{0xff, 0xf8, byte(i)})
+		audio = append(audio, frame...)
+	}
+
+	data := []byte("fLaC")
+	data = append(data, flacBlock(0, false, flacStreamInfo(1000, 60000))...)
+	data = append(data, flacBlock(4, true, vorbisComment("TITLE=Excerpt"))...)
+	data = append(data, audio...)
+
+	excerpt, err := extractExcerpt(data, ".flac", 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Only STREAMINFO is kept, with an unknown length and MD5
+	if !bytes.HasPrefix(excerpt, []byte("fLaC\x80\x00\x00\x22")) {
+		t.Fatalf("Unexpected metadata header %x", excerpt[:8])
+	}
+	info := excerpt[8:42]
+	if info[13]&0x0f != 0 || !bytes.Equal(info[14:34], make([]byte, 20)) {
+		t.Error("Total samples and MD5 not cleared")
+	}
+
+	frames := excerpt[42:]
+	if len(frames) != 10*100 || frames[2] != 20 {
+		t.Errorf("Excerpt of %v bytes from frame %v, expected 10 frames from frame 20", len(frames), frames[2])
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Not FLAC", []byte("OggS")},
+		{"No STREAMINFO", append([]byte("fLaC"), flacBlock(4, true, vorbisComment())...)},
+		{"Unknown duration", append([]byte("fLaC"), flacBlock(0, true, flacStreamInfo(1000, 0))...)},
+		{"Truncated metadata", append([]byte("fLaC"), flacBlock(0, true, flacStreamInfo(1000, 60000))[:20]...)},
+	}
+	for _, test := range tests {
+		if _, err := extractExcerpt(test.data, ".flac", 10); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+func TestExtractExcerptUnsupported(t *testing.T) {
+	if _, err := extractExcerpt([]byte("RIFF"), ".wav", 30); err == nil {
+		t.Error("Expected an error for a WAV file")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Recent events kept for spectators resuming with Last-Event-ID
+	feedBufferSize = 100
+	// Events queued for a spectator before it is dropped, to resume later
+	feedSubscriberBuffer = 32
+	feedKeepAlive        = 15 * time.Second
+)
+
+// Event of the spectator feed
+type FeedEvent struct {
+	ID   uint64
+	Type string
+	Data interface{}
+}
+
+type FeedRoundStartedEvent struct {
+	Round          int              `json:"round"`
+	Rounds         int              `json:"rounds"`
+	RoundType      string           `json:"round_type"`
+	SongPreviewURI string           `json:"preview_uri"`
+	Choices        []SocketIOChoice `json:"choices,omitempty"`
+}
+
+type FeedRevealEvent struct {
+	Round  int                  `json:"round"`
+	Song   Song                 `json:"song"`
+	Scores []SocketIORoundScore `json:"scores"`
+}
+
+type FeedLeaderBoardEvent struct {
+	LeaderBoard []SocketIOPlayer `json:"leaderboard"`
+	Teams       []Team           `json:"teams"`
+}
+
+// SpectatorFeed is the read-only event stream of a room, for scoreboards. Published by the room
+// loop, and read by the HTTP handlers of the spectators.
+type SpectatorFeed struct {
+	mu sync.Mutex
+	// Ring buffer of the recent events, the oldest at start
+	events []FeedEvent
+	start  int
+	lastID uint64
+	// Last leaderboard published, sent to new spectators
+	leaderBoard *FeedEvent
+	subscribers map[chan FeedEvent]bool
+	closed      bool
+}
+
+func newSpectatorFeed() *SpectatorFeed {
+	return &SpectatorFeed{
+		events:      make([]FeedEvent, 0, feedBufferSize),
+		subscribers: make(map[chan FeedEvent]bool),
+	}
+}
+
+func (f *SpectatorFeed) publish(eventType string, data interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.publishLocked(eventType, data)
+}
+
+func (f *SpectatorFeed) publishLocked(eventType string, data interface{}) FeedEvent {
+	f.lastID++
+	event := FeedEvent{ID: f.lastID, Type: eventType, Data: data}
+
+	if len(f.events) < feedBufferSize {
+		f.events = append(f.events, event)
+	} else {
+		f.events[f.start] = event
+		f.start = (f.start + 1) % feedBufferSize
+	}
+
+	for subscriber := range f.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			// Too slow, it resumes from the buffer once reconnected
+			delete(f.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return event
+}
+
+// Publishes the leaderboard, unless it didn't change since the last one
+func (f *SpectatorFeed) publishLeaderBoard(leaderBoard FeedLeaderBoardEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.leaderBoard != nil && reflect.DeepEqual(f.leaderBoard.Data, leaderBoard) {
+		return
+	}
+
+	event := f.publishLocked("leaderboard", leaderBoard)
+	f.leaderBoard = &event
+}
+
+// Returns the events to send a new spectator first, and the channel of the next ones.
+// A spectator resuming gets the buffered events following lastID, a new one the leaderboard.
+// So does a spectator whose missed events aren't all buffered anymore, or who resumes an id
+// this feed never sent, as it would only get part of what happened.
+func (f *SpectatorFeed) subscribe(lastID uint64, resuming bool) ([]FeedEvent, chan FeedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if resuming && (lastID > f.lastID || len(f.events) > 0 && lastID < f.events[f.start].ID-1) {
+		resuming = false
+	}
+
+	backlog := make([]FeedEvent, 0)
+	if resuming {
+		for i := range f.events {
+			event := f.events[(f.start+i)%len(f.events)]
+			if event.ID > lastID {
+				backlog = append(backlog, event)
+			}
+		}
+	} else if f.leaderBoard != nil {
+		backlog = append(backlog, *f.leaderBoard)
+	}
+
+	subscriber := make(chan FeedEvent, feedSubscriberBuffer)
+	if f.closed {
+		close(subscriber)
+	} else {
+		f.subscribers[subscriber] = true
+	}
+
+	return backlog, subscriber
+}
+
+func (f *SpectatorFeed) unsubscribe(subscriber chan FeedEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.subscribers[subscriber] {
+		delete(f.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// Ends the streams of every spectator, once the room closed
+func (f *SpectatorFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for subscriber := range f.subscribers {
+		delete(f.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+func (r *Room) publishLeaderBoard() {
+	r.feed.publishLeaderBoard(FeedLeaderBoardEvent{
+		LeaderBoard: newSocketIOPlayers(*r.Game.getLeaderBoard()),
+		Teams:       copyTeams(r.Game.getTeamLeaderBoard()),
+	})
+}
+
+// Streams the spectator feed of a room as Server-Sent Events, resuming after the Last-Event-ID
+// header when the events missed are still buffered
+func handleRoomEvents(c *gin.Context) {
+	room, err := rooms.get(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	lastID, err := strconv.ParseUint(lastEventID, 10, 64)
+	resuming := lastEventID != "" && err == nil
+
+	backlog, events := room.feed.subscribe(lastID, resuming)
+	defer room.feed.unsubscribe(events)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	render := func(event FeedEvent) {
+		c.Render(-1, sse.Event{Id: strconv.FormatUint(event.ID, 10), Event: event.Type, Data: event.Data})
+	}
+	for _, event := range backlog {
+		render(event)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(feedKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			render(event)
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestSpectatorFeedSubscribe(t *testing.T) {
+	feed := newSpectatorFeed()
+	feed.publishLeaderBoard(FeedLeaderBoardEvent{LeaderBoard: []SocketIOPlayer{{Name: "Alice", Score: 10}}})
+	for i := 0; i < feedBufferSize+49; i++ {
+		feed.publish("roundStarted", FeedRoundStartedEvent{Round: i})
+	}
+	// Ids 1 to 150, the buffer keeping 51 to 150
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		resuming bool
+		// Ids of the backlog
+		first, count uint64
+	}{
+		{"New spectator", 0, false, 1, 1},
+		{"Up to date", 150, true, 0, 0},
+		{"Missed buffered events", 140, true, 141, 10},
+		{"Missed every buffered event", 50, true, 51, 100},
+		{"Missed dropped events", 49, true, 1, 1},
+		{"Resuming another feed", 151, true, 1, 1},
+	}
+
+	for _, test := range tests {
+		backlog, events := feed.subscribe(test.lastID, test.resuming)
+		feed.unsubscribe(events)
+
+		first := uint64(0)
+		if len(backlog) > 0 {
+			first = backlog[0].ID
+		}
+		if uint64(len(backlog)) != test.count || first != test.first {
+			t.Errorf("%v: got %v events from %v, expected %v from %v", test.name, len(backlog), first, test.count, test.first)
+		}
+	}
+}
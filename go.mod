@@ -3,8 +3,9 @@ module test-sse
 go 1.14
 
 require (
+	github.com/gin-contrib/sse v0.1.0
 	github.com/gin-gonic/gin v1.6.2
-	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/hbakhtiyor/strsim v0.0.0-20190107154042-4d2bbb273edf
 	github.com/mlsquires/socketio v0.0.0-20180414171845-169a6f09e624
 	github.com/pschlump/MiscLib v1.0.0 // indirect
@@ -13,5 +14,7 @@ require (
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirupsen/logrus v1.5.0 // indirect
 	github.com/smartystreets/goconvey v1.6.4 // indirect
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/text v0.3.2
+	gopkg.in/yaml.v2 v2.2.8
 )
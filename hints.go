@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Masked song fields, more letters being revealed at each hint level:
+// 1 only shows the words, 2 their first letter, then every level reveals another quarter of the letters
+type SocketIOHintEvent struct {
+	Level       int    `json:"level"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	TitleWords  int    `json:"title_words"`
+	ArtistWords int    `json:"artist_words"`
+}
+
+// Gives the hints whose mark has been reached, according to the time left
+func (r *Room) giveHints() {
+	round := &r.Game.CurrentRound
+	settings := &r.Game.Settings
+
+	// Hints are about the artist and title, which the choices already give away
+	if round.Type != songRound || settings.Mode == multipleChoiceMode {
+		return
+	}
+
+	elapsed := settings.GuessTime - round.TimeLeft
+	for round.Hints < len(settings.HintMarks) && elapsed*100 >= settings.HintMarks[round.Hints]*settings.GuessTime {
+		round.Hints++
+		r.record(MatchLogEntry{Type: logHint, Hints: round.Hints})
+
+		title, artist := hintValue(round.Song, titleField), hintValue(round.Song, artistField)
+		r.broadcast("hint", SocketIOHintEvent{
+			Level:       round.Hints,
+			Title:       maskHint(title, round.Hints, round.revealOrder(titleField, r.Game.random)),
+			Artist:      maskHint(artist, round.Hints, round.revealOrder(artistField, r.Game.random)),
+			TitleWords:  len(strings.Fields(title)),
+			ArtistWords: len(strings.Fields(artist)),
+		})
+	}
+}
+
+// Returns field as hints show it, without what players don't need to type: the normalized title,
+// and the main artist rather than the whole credit
+func hintValue(song Song, field string) string {
+	value := normalizeTitle(song.Title)
+	if field == artistField {
+		value = mainArtist(song)
+	}
+
+	// Nothing left once normalized, e.g. '(Untitled)'
+	if value == "" {
+		if field == artistField {
+			return song.Artist.Name
+		}
+		return song.Title
+	}
+	return value
+}
+
+// Returns the random order letters of field are revealed in, drawn once per round
+func (r *Round) revealOrder(field string, random Random) []int {
+	if order, ok := r.hintOrders[field]; ok {
+		return order
+	}
+
+	value := hintValue(r.Song, field)
+
+	// First letters are revealed by the level 2 hint, the other letters after that
+	order := make([]int, 0)
+	for i, c := range []rune(value) {
+		if isHintLetter(c) && !isWordStart([]rune(value), i) {
+			order = append(order, i)
+		}
+	}
+	for i := len(order) - 1; i > 0; i-- {
+		j := random.Intn(i + 1)
+		order[i], order[j] = order[j], order[i]
+	}
+
+	if r.hintOrders == nil {
+		r.hintOrders = make(map[string][]int)
+	}
+	r.hintOrders[field] = order
+
+	return order
+}
+
+func isHintLetter(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func isWordStart(runes []rune, i int) bool {
+	return isHintLetter(runes[i]) && (i == 0 || !isHintLetter(runes[i-1]))
+}
+
+// Masks s for the given hint level, e.g. "H _ _   J _ _ e".
+// Letters are separated by a space, and words by three.
+func maskHint(s string, level int, order []int) string {
+	runes := []rune(s)
+
+	revealed := make(map[int]bool)
+	if level >= 3 {
+		count := len(order) * (level - 2) / 4
+		if count > len(order) {
+			count = len(order)
+		}
+		for _, i := range order[:count] {
+			revealed[i] = true
+		}
+	}
+
+	masked := make([]string, len(runes))
+	for i, c := range runes {
+		switch {
+		case unicode.IsSpace(c):
+			masked[i] = " "
+		case !isHintLetter(c) || revealed[i] || (level >= 2 && isWordStart(runes, i)):
+			masked[i] = string(c)
+		default:
+			masked[i] = "_"
+		}
+	}
+
+	return strings.Join(masked, " ")
+}
+
+// Points removed from base once hints were given
+func (s *GameSettings) hintPenalty(base, hints int) int {
+	penalty := base * s.HintPenalty * hints / 100
+	if penalty > base {
+		penalty = base
+	}
+
+	return penalty
+}
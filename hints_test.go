@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHintValue(t *testing.T) {
+	tests := []struct {
+		title, artist string
+		// Masked as the level 2 hint, and number of words
+		title2, artist2         string
+		titleWords, artistWords int
+	}{
+		{"Hey Jude (Remastered 2015)", "The Beatles", "H _ _   J _ _ _", "T _ _   B _ _ _ _ _ _", 2, 2},
+		{"Get Lucky (feat. Pharrell Williams)", "Daft Punk", "G _ _   L _ _ _ _", "D _ _ _   P _ _ _", 2, 2},
+		{"Get Lucky", "Daft Punk feat. Pharrell Williams", "G _ _   L _ _ _ _", "D _ _ _   P _ _ _", 2, 2},
+		{"Under Pressure - 2011 Remaster", "Queen / David Bowie", "U _ _ _ _   P _ _ _ _ _ _ _", "Q _ _ _ _", 2, 1},
+		{"The Boxer", "Simon & Garfunkel", "B _ _ _ _", "S _ _ _ _   &   G _ _ _ _ _ _ _ _", 1, 3},
+		{"(Untitled)", "AC/DC", "( U _ _ _ _ _ _ _ )", "A _ / D _", 1, 1},
+	}
+
+	for _, test := range tests {
+		song := Song{Title: test.title, Artist: Artist{Name: test.artist}}
+		title, artist := hintValue(song, titleField), hintValue(song, artistField)
+
+		if got := maskHint(title, 2, nil); got != test.title2 {
+			t.Errorf("Title hint of %q: %q, expected %q", test.title, got, test.title2)
+		}
+		if got := maskHint(artist, 2, nil); got != test.artist2 {
+			t.Errorf("Artist hint of %q: %q, expected %q", test.artist, got, test.artist2)
+		}
+		if words := len(strings.Fields(title)); words != test.titleWords {
+			t.Errorf("%v words in the title hint of %q, expected %v", words, test.title, test.titleWords)
+		}
+		if words := len(strings.Fields(artist)); words != test.artistWords {
+			t.Errorf("%v words in the artist hint of %q, expected %v", words, test.artist, test.artistWords)
+		}
+	}
+}
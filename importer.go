@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problem found on a line of an imported playlist file.
+// For JSON files, Line is the position of the song in the 'songs' array (starting at 1)
+type ImportError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+type importedSong struct {
+	Line int
+	Song Song
+}
+
+// Columns of the CSV file holding each song field, by header name
+type CSVColumns struct {
+	Title   string
+	Artist  string
+	Preview string
+	Album   string
+	Year    string
+	Genre   string
+	// Field separator, ',' by default
+	Separator rune
+}
+
+func defaultCSVColumns() CSVColumns {
+	return CSVColumns{
+		Title:     "title",
+		Artist:    "artist",
+		Preview:   "preview",
+		Album:     "album",
+		Year:      "year",
+		Genre:     "genre",
+		Separator: ',',
+	}
+}
+
+// JSON playlist schema:
+//
+//	{
+//		"songs": [
+//			{
+//				"title": "Harder, Better, Faster, Stronger",  // required
+//				"artist": "Daft Punk",                        // required
+//				"preview": "https://example.com/hbfs.mp3",    // required, http(s) URL
+//				"album": "Discovery",
+//				"year": 2001,
+//				"genre": "Electro",
+//				"artist_picture": "https://example.com/daftpunk.jpg"
+//			}
+//		]
+//	}
+type jsonPlaylist struct {
+	Songs []jsonSong `json:"songs"`
+}
+
+type jsonSong struct {
+	Title         string `json:"title"`
+	Artist        string `json:"artist"`
+	Preview       string `json:"preview"`
+	Album         string `json:"album"`
+	Year          int    `json:"year"`
+	Genre         string `json:"genre"`
+	ArtistPicture string `json:"artist_picture"`
+}
+
+// Parses an extended M3U playlist, where '#EXTINF:<duration>,<artist> - <title>' precedes each song URI
+func parseM3U(r io.Reader) ([]importedSong, []ImportError, error) {
+	songs := make([]importedSong, 0)
+	errs := make([]ImportError, 0)
+
+	var current *importedSong
+	scanner := bufio.NewScanner(r)
+	lineNb := 0
+	for scanner.Scan() {
+		lineNb++
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		switch {
+		case line == "" || line == "#EXTM3U":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			if current != nil {
+				errs = append(errs, ImportError{Line: current.Line, Reason: "missing preview"})
+			}
+			current = &importedSong{Line: lineNb}
+
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			comma := strings.Index(info, ",")
+			if comma < 0 {
+				continue
+			}
+			parts := strings.SplitN(info[comma+1:], " - ", 2)
+			if len(parts) == 2 {
+				current.Song.Artist.Name = strings.TrimSpace(parts[0])
+				current.Song.Title = strings.TrimSpace(parts[1])
+			} else {
+				current.Song.Title = strings.TrimSpace(parts[0])
+			}
+		case strings.HasPrefix(line, "#"):
+			// Other directives are not supported
+			continue
+		default:
+			if current == nil {
+				current = &importedSong{Line: lineNb}
+			}
+			current.Song.Preview = line
+			songs = append(songs, *current)
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if current != nil {
+		errs = append(errs, ImportError{Line: current.Line, Reason: "missing preview"})
+	}
+
+	return songs, errs, nil
+}
+
+// Parses a CSV playlist whose first row holds the column names
+func parseCSV(r io.Reader, columns CSVColumns) ([]importedSong, []ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = columns.Separator
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Can't read CSV header. Err: %v", err)
+	}
+
+	indexes := make(map[string]int)
+	for i, name := range header {
+		indexes[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	for _, required := range []string{columns.Title, columns.Artist, columns.Preview} {
+		if _, ok := indexes[strings.ToLower(required)]; !ok {
+			return nil, nil, fmt.Errorf("Column '%v' not found in CSV header", required)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := indexes[strings.ToLower(column)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	songs := make([]importedSong, 0)
+	errs := make([]ImportError, 0)
+	// Header is line 1
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			errs = append(errs, ImportError{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		song := Song{
+			Title:   field(record, columns.Title),
+			Preview: field(record, columns.Preview),
+			Album:   field(record, columns.Album),
+			Genre:   field(record, columns.Genre),
+			Artist:  Artist{Name: field(record, columns.Artist)},
+		}
+
+		if year := field(record, columns.Year); year != "" {
+			song.Year, err = strconv.Atoi(year)
+			if err != nil {
+				errs = append(errs, ImportError{Line: line, Reason: fmt.Sprintf("invalid year '%v'", year)})
+				continue
+			}
+		}
+
+		songs = append(songs, importedSong{Line: line, Song: song})
+	}
+
+	return songs, errs, nil
+}
+
+func parseJSONPlaylist(r io.Reader) ([]importedSong, []ImportError, error) {
+	var playlist jsonPlaylist
+	if err := json.NewDecoder(r).Decode(&playlist); err != nil {
+		return nil, nil, fmt.Errorf("Can't decode JSON playlist. Err: %v", err)
+	}
+
+	songs := make([]importedSong, len(playlist.Songs))
+	for i, s := range playlist.Songs {
+		songs[i] = importedSong{
+			Line: i + 1,
+			Song: Song{
+				Title:   s.Title,
+				Preview: s.Preview,
+				Album:   s.Album,
+				Year:    s.Year,
+				Genre:   s.Genre,
+				Artist:  Artist{Name: s.Artist, Picture: s.ArtistPicture},
+			},
+		}
+	}
+
+	return songs, make([]ImportError, 0), nil
+}
+
+// Returns why the song can't be played, if it can't
+func validateSong(song Song) error {
+	switch {
+	case song.Preview == "":
+		return errors.New("missing preview")
+	case song.Title == "":
+		return errors.New("missing title")
+	case song.Artist.Name == "":
+		return errors.New("missing artist")
+	}
+
+	previewURL, err := url.Parse(song.Preview)
+	if err != nil || (previewURL.Scheme != "http" && previewURL.Scheme != "https") {
+		return errors.New("preview is not an http(s) URL")
+	}
+
+	return nil
+}
+
+// Builds a playlist from the valid imported songs, reporting the invalid ones
+func newImportedPlaylist(songs []importedSong, errs []ImportError) (*Playlist, []ImportError) {
+	valid := make([]Song, 0)
+
+	for _, s := range songs {
+		if err := validateSong(s.Song); err != nil {
+			errs = append(errs, ImportError{Line: s.Line, Reason: err.Error()})
+			continue
+		}
+
+		s.Song.ID = strconv.Itoa(s.Line)
+		valid = append(valid, s.Song)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Line < errs[j].Line
+	})
+
+	return &Playlist{Songs: valid, Length: len(valid)}, errs
+}
+
+func importPlaylist(r io.Reader, format string, columns CSVColumns) (*Playlist, []ImportError, error) {
+	var songs []importedSong
+	var errs []ImportError
+	var err error
+
+	switch format {
+	case "m3u", "m3u8":
+		songs, errs, err = parseM3U(r)
+	case "csv":
+		songs, errs, err = parseCSV(r, columns)
+	case "json":
+		songs, errs, err = parseJSONPlaylist(r)
+	default:
+		return nil, nil, fmt.Errorf("Unsupported playlist format '%v'", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	playlist, errs := newImportedPlaylist(songs, errs)
+
+	return playlist, errs, nil
+}
+
+// Replaces the playlist of a room with an uploaded M3U, CSV or JSON file.
+// Expects a multipart form with 'token' (the host session token) and 'file', and optionally
+// 'format' and the CSV '<field>_column' / 'separator' settings.
+func handlePlaylistUpload(c *gin.Context) {
+	claims, err := rooms.sessions.verify(c.PostForm("token"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	room, err := rooms.get(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Field 'file' required"})
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+
+	columns := defaultCSVColumns()
+	columns.Title = c.DefaultPostForm("title_column", columns.Title)
+	columns.Artist = c.DefaultPostForm("artist_column", columns.Artist)
+	columns.Preview = c.DefaultPostForm("preview_column", columns.Preview)
+	columns.Album = c.DefaultPostForm("album_column", columns.Album)
+	columns.Year = c.DefaultPostForm("year_column", columns.Year)
+	columns.Genre = c.DefaultPostForm("genre_column", columns.Genre)
+	if separator := c.PostForm("separator"); separator != "" {
+		columns.Separator, _ = utf8.DecodeRuneInString(separator)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	playlist, errs, err := importPlaylist(file, format, columns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if playlist.Length == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Playlist has no playable song", "errors": errs})
+		return
+	}
+
+	cmd := importPlaylistCommand{
+		claims:   claims,
+		playlist: *playlist,
+		result:   make(chan error, 1),
+	}
+	if !room.send(cmd) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room closed"})
+		return
+	}
+	if err := <-cmd.result; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imported": playlist.Length, "errors": errs})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportPlaylist(t *testing.T) {
+	customColumns := defaultCSVColumns()
+	customColumns.Title = "Track"
+	customColumns.Artist = "Performer"
+	customColumns.Preview = "URL"
+	customColumns.Separator = ';'
+
+	tests := []struct {
+		name    string
+		format  string
+		columns CSVColumns
+		data    string
+		// Titles of the imported songs
+		titles []string
+		errs   []ImportError
+	}{
+		{
+			"M3U",
+			"m3u",
+			defaultCSVColumns(),
+			"#EXTM3U\n" +
+				"#EXTINF:30,Daft Punk - One More Time\n" +
+				"https://example.com/1.mp3\n" +
+				"\n" +
+				"#EXTINF:-1,Untitled artistless\n" +
+				"http://example.com/2.mp3\n",
+			[]string{"One More Time"},
+			[]ImportError{{Line: 5, Reason: "missing artist"}},
+		},
+		{
+			"M3U #EXTINF without URI",
+			"m3u",
+			defaultCSVColumns(),
+			"#EXTINF:30,Blur - Song 2\n" +
+				"#EXTINF:30,Oasis - Wonderwall\n" +
+				"https://example.com/wonderwall.mp3\n" +
+				"#EXTINF:30,Pulp - Common People\n",
+			[]string{"Wonderwall"},
+			[]ImportError{{Line: 1, Reason: "missing preview"}, {Line: 4, Reason: "missing preview"}},
+		},
+		{
+			"M3U URI without #EXTINF",
+			"m3u",
+			defaultCSVColumns(),
+			"https://example.com/1.mp3\n" +
+				"#EXTINF:30,Daft Punk - Aerodynamic\n" +
+				"https://example.com/2.mp3\n",
+			[]string{"Aerodynamic"},
+			[]ImportError{{Line: 1, Reason: "missing title"}},
+		},
+		{
+			"Non-http preview",
+			"m3u",
+			defaultCSVColumns(),
+			"#EXTINF:30,Daft Punk - Digital Love\n" +
+				"file:///music/digital-love.mp3\n" +
+				"#EXTINF:30,Daft Punk - Voyager\n" +
+				"/music/voyager.mp3\n",
+			[]string{},
+			[]ImportError{{Line: 1, Reason: "preview is not an http(s) URL"}, {Line: 3, Reason: "preview is not an http(s) URL"}},
+		},
+		{
+			"Errors ordered by line",
+			"m3u",
+			defaultCSVColumns(),
+			"#EXTINF:30,Pulp - Disco 2000\n" +
+				"#EXTINF:30,No Artist\n" +
+				"https://example.com/2.mp3\n" +
+				"#EXTINF:30,Pulp - Babies\n",
+			[]string{},
+			[]ImportError{{Line: 1, Reason: "missing preview"}, {Line: 2, Reason: "missing artist"}, {Line: 4, Reason: "missing preview"}},
+		},
+		{
+			"CSV",
+			"csv",
+			defaultCSVColumns(),
+			"\ufefftitle,artist,preview,album,year,genre\n" +
+				"Hey Jude,The Beatles,https://example.com/1.mp3,Single,1968,Rock\n" +
+				"Help!,The Beatles,https://example.com/2.mp3\n",
+			[]string{"Hey Jude", "Help!"},
+			[]ImportError{},
+		},
+		{
+			"CSV custom columns and separator",
+			"csv",
+			customColumns,
+			"URL;Performer;Track\n" +
+				"https://example.com/1.mp3;Queen;Bohemian Rhapsody, Live\n" +
+				"https://example.com/2.mp3;;Untitled\n",
+			[]string{"Bohemian Rhapsody, Live"},
+			[]ImportError{{Line: 3, Reason: "missing artist"}},
+		},
+		{
+			"CSV bad year",
+			"csv",
+			defaultCSVColumns(),
+			"title,artist,preview,year\n" +
+				"Hey Jude,The Beatles,https://example.com/1.mp3,sixties\n" +
+				"Help!,The Beatles,https://example.com/2.mp3,1965\n",
+			[]string{"Help!"},
+			[]ImportError{{Line: 2, Reason: "invalid year 'sixties'"}},
+		},
+		{
+			"JSON",
+			"json",
+			defaultCSVColumns(),
+			`{"songs": [
+				{"title": "Around the World", "artist": "Daft Punk", "preview": "https://example.com/1.mp3", "year": 1997},
+				{"title": "Da Funk", "preview": "https://example.com/2.mp3"},
+				{"title": "Revolution 909", "artist": "Daft Punk", "preview": "ftp://example.com/3.mp3"}
+			]}`,
+			[]string{"Around the World"},
+			[]ImportError{{Line: 2, Reason: "missing artist"}, {Line: 3, Reason: "preview is not an http(s) URL"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			playlist, errs, err := importPlaylist(strings.NewReader(test.data), test.format, test.columns)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			titles := make([]string, 0)
+			for _, song := range playlist.Songs {
+				titles = append(titles, song.Title)
+			}
+			if !reflect.DeepEqual(titles, test.titles) || playlist.Length != len(test.titles) {
+				t.Errorf("Imported %q, expected %q", titles, test.titles)
+			}
+			if !reflect.DeepEqual(errs, test.errs) {
+				t.Errorf("Got errors %+v, expected %+v", errs, test.errs)
+			}
+		})
+	}
+}
+
+func TestImportPlaylistErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"CSV without preview column", "csv", "title,artist\nHey Jude,The Beatles\n"},
+		{"CSV header without the configured columns", "csv", "Track;Performer;URL\n"},
+		{"Empty CSV", "csv", ""},
+		{"Invalid JSON", "json", `{"songs": [`},
+		{"Unknown format", "xspf", "<playlist/>"},
+	}
+
+	for _, test := range tests {
+		if _, _, err := importPlaylist(strings.NewReader(test.data), test.format, defaultCSVColumns()); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var audioContentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".flac": "audio/flac",
+}
+
+// LocalSource reads songs from a directory of audio files, and serves their excerpts itself
+type LocalSource struct {
+	Dir string
+	// Base URL the server is reachable at by players, used to build preview URLs
+	PublicURL string
+	songs     []Song
+	// Audio file path, by song id
+	files map[string]string
+}
+
+func newLocalSource(dir, publicURL string) (*LocalSource, error) {
+	source := &LocalSource{
+		Dir:       dir,
+		PublicURL: strings.TrimRight(publicURL, "/"),
+		songs:     make([]Song, 0),
+		files:     make(map[string]string),
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		extension := strings.ToLower(filepath.Ext(path))
+		if info.IsDir() || audioContentTypes[extension] == "" {
+			return nil
+		}
+
+		song, err := source.readSong(path, extension)
+		if err != nil {
+			log.Printf("Skipping %v. Err: %v", path, err)
+			return nil
+		}
+
+		source.songs = append(source.songs, song)
+		source.files[song.ID] = path
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("%v songs found in %v", len(source.songs), dir)
+
+	return source, nil
+}
+
+func (l *LocalSource) readSong(path, extension string) (Song, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Song{}, err
+	}
+	defer file.Close()
+
+	var tags audioTags
+	switch extension {
+	case ".mp3":
+		tags, err = readID3v2Tags(file)
+	case ".flac":
+		tags, err = readFLACTags(file)
+	default:
+		tags, err = readOggTags(file)
+	}
+	if err != nil {
+		log.Printf("Can't read tags of %v, falling back to file name. Err: %v", path, err)
+	}
+
+	// Fall back on 'Artist - Title' file names
+	if tags.Title == "" || tags.Artist == "" {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if parts := strings.SplitN(name, " - ", 2); len(parts) == 2 {
+			if tags.Artist == "" {
+				tags.Artist = strings.TrimSpace(parts[0])
+			}
+			if tags.Title == "" {
+				tags.Title = strings.TrimSpace(parts[1])
+			}
+		}
+	}
+
+	if tags.Title == "" || tags.Artist == "" {
+		return Song{}, errors.New("Missing title or artist")
+	}
+
+	relativePath, err := filepath.Rel(l.Dir, path)
+	if err != nil {
+		relativePath = path
+	}
+	hash := sha1.Sum([]byte(relativePath))
+	id := hex.EncodeToString(hash[:8])
+
+	return Song{
+		ID:      id,
+		Preview: l.previewURL(id),
+		Title:   tags.Title,
+		Album:   tags.Album,
+		Year:    tags.Year,
+		Genre:   tags.Genre,
+		Artist:  Artist{Name: tags.Artist},
+	}, nil
+}
+
+func (l *LocalSource) previewURL(id string) string {
+	return fmt.Sprintf("%v/songs/%v/preview", l.PublicURL, id)
+}
+
+func (l *LocalSource) ListTracks() ([]Song, error) {
+	songs := make([]Song, len(l.songs))
+	copy(songs, l.songs)
+
+	return songs, nil
+}
+
+func (l *LocalSource) Resolve(song Song) (Song, error) {
+	if _, ok := l.files[song.ID]; !ok {
+		return song, errors.New("Song not found")
+	}
+
+	song.Preview = l.previewURL(song.ID)
+	return song, nil
+}
+
+// Local files carry no artist picture
+func (l *LocalSource) ArtistPicture(artist Artist) (string, error) {
+	return artist.Picture, nil
+}
+
+// Serves an excerpt of the song matching the 'id' route param
+func (l *LocalSource) servePreview(c *gin.Context) {
+	path, ok := l.files[c.Param("id")]
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("Can't read %v. Err: %v", path, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	extension := strings.ToLower(filepath.Ext(path))
+	excerpt, err := extractExcerpt(data, extension, excerptLength)
+	if err != nil {
+		log.Printf("Can't extract excerpt of %v. Err: %v", path, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", audioContentTypes[extension])
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), bytes.NewReader(excerpt))
+}
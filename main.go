@@ -1,26 +1,98 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"github.com/gin-gonic/gin"
 	"github.com/mlsquires/socketio"
 	"log"
-	"net/http"
-	"time"
+	"os"
 )
 
 const (
-	playlistURI = "https://api.deezer.com/playlist/7530596462/tracks"
+	playlistURI      = "https://api.deezer.com/playlist/7530596462/tracks"
+	defaultPublicURL = "http://localhost:8080"
 )
 
 var socketIOServer *socketio.Server
-var game Game
-var playlist Playlist
+var rooms *RoomRegistry
 
 func main() {
+	replayPath := flag.String("replay", "", "Match log to replay, comparing the rebuilt leaderboard with the broadcast one")
+	flag.Parse()
+
 	var err error
+
+	// Loaded first, as replayed guesses may have been credited thanks to an alias
+	if aliasesFile := os.Getenv("ALIASES_FILE"); aliasesFile != "" {
+		aliases, err = loadAliasDictionary(aliasesFile)
+		if err != nil {
+			log.Fatalf("Can't load aliases. Err: %v", err)
+		}
+	}
+
+	if *replayPath != "" {
+		os.Exit(runReplay(*replayPath))
+	}
+
 	router := initRouter()
 
+	var source SongSource = newDeezerSource(playlistURI)
+
+	// Play songs from a local folder instead of Deezer, for offline parties
+	if musicDir := os.Getenv("MUSIC_DIR"); musicDir != "" {
+		publicURL := os.Getenv("PUBLIC_URL")
+		if publicURL == "" {
+			publicURL = defaultPublicURL
+		}
+
+		localSource, err := newLocalSource(musicDir, publicURL)
+		if err != nil {
+			log.Fatalf("Can't read music folder. Err: %v", err)
+		}
+
+		router.GET("songs/:id/preview", localSource.servePreview)
+		source = localSource
+	}
+
+	// The admin API is only served when a token protects it
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		registerAdminRoutes(router, adminToken)
+	}
+
+	// Persist rooms, so that matches survive restarts
+	var store RoomStore
+	if stateDB := os.Getenv("STATE_DB"); stateDB != "" {
+		boltStore, err := newBoltStore(stateDB)
+		if err != nil {
+			log.Fatalf("Can't open state database. Err: %v", err)
+		}
+		defer boltStore.Close()
+		store = boltStore
+	} else if stateDir := os.Getenv("STATE_DIR"); stateDir != "" {
+		store, err = newFileStore(stateDir)
+		if err != nil {
+			log.Fatalf("Can't open state folder. Err: %v", err)
+		}
+	}
+
+	// Log every match event, so that matches can be replayed
+	matchLogDir := os.Getenv("MATCH_LOG_DIR")
+	if matchLogDir != "" {
+		if err := os.MkdirAll(matchLogDir, 0755); err != nil {
+			log.Fatalf("Can't create match log folder. Err: %v", err)
+		}
+	}
+
+	// Tokens only survive restarts when signed with a set secret
+	sessions := newRandomSessionSigner()
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		sessions = newSessionSigner([]byte(secret))
+	} else if store != nil {
+		log.Printf("SESSION_SECRET not set, players won't get back in restored rooms")
+	}
+
+	rooms = newRoomRegistry(source, store, matchLogDir, sessions, realClock{})
+
 	socketIOServer, err = socketio.NewServer(nil)
 	if err != nil {
 		log.Fatal(err)
@@ -29,44 +101,97 @@ func main() {
 	socketIOServer.On("connection", func(so socketio.Socket) {
 		log.Printf("Socket %v connected", so.Id())
 
-		so.Join("gameRoom")
+		client := newSocketIOClient(so)
+		go client.writeLoop()
+		conn := newConnection(client)
 
 		so.On("join", func(params map[string]string) {
-			playerName, ok := params["player_name"]
+			roomCode, ok := params["room_code"]
+			if !ok {
+				client.Emit("error", "Field 'room_code' required")
+				return
+			}
 
+			playerName, ok := params["player_name"]
 			if !ok {
-				so.Emit("error", "Field 'player_name' required")
+				client.Emit("error", "Field 'player_name' required")
 				return
 			}
 
-			handleJoinEvent(so, playerName)
+			conn.join(roomCode, playerName)
 		})
 
 		so.On("playerReconnect", func(params map[string]string) {
-			playerID, ok := params["player_id"]
+			token, ok := params["token"]
+			if !ok {
+				client.Emit("error", "Field 'token' required")
+				return
+			}
+
+			conn.reconnect(token)
+		})
+
+		so.On("configure", func(params map[string]interface{}) {
+			conn.configure(params)
+		})
+
+		so.On("start", func() {
+			conn.start()
+		})
+
+		so.On("buzz", func() {
+			conn.buzz()
+		})
+
+		so.On("choose", func(params map[string]interface{}) {
+			option, ok := params["option"].(float64)
 
 			if !ok {
-				so.Emit("error", "Field 'player_id' required")
+				client.Emit("error", "Field 'option' required")
+				return
+			}
+
+			conn.choose(int(option))
+		})
+
+		so.On("joinTeam", func(params map[string]string) {
+			teamName, ok := params["team_name"]
+			if !ok || teamName == "" {
+				client.Emit("error", "Field 'team_name' required")
+				return
+			}
+
+			conn.joinTeam(teamName, false)
+		})
+
+		so.On("switchTeam", func(params map[string]string) {
+			teamName, ok := params["team_name"]
+			if !ok || teamName == "" {
+				client.Emit("error", "Field 'team_name' required")
 				return
 			}
 
-			handleReconnectEvent(so, playerID)
+			conn.joinTeam(teamName, true)
+		})
+
+		so.On("leave", func() {
+			conn.leave()
 		})
 
 		so.On("disconnect", func() {
-			log.Printf("Socket id: %v) left the game", so.Id())
+			conn.disconnect()
+			client.close()
 		})
 
 		so.On("guess", func(params map[string]string) {
-			playerID, ok := params["player_id"]
 			playerGuess, ok := params["guess"]
 
 			if !ok {
-				so.Emit("error", "Field 'player_id' and 'guess' required")
+				client.Emit("error", "Field 'guess' required")
 				return
 			}
 
-			handleGuessEvent(so, playerID, playerGuess)
+			conn.guess(playerGuess)
 		})
 	})
 
@@ -74,10 +199,11 @@ func main() {
 		log.Printf("Error: %v", err.Error())
 	})
 
-	playlist = *getPlaylist(playlistURI)
-	game = newGame(make([]*Player, 0))
+	router.POST("rooms/:code/playlist", handlePlaylistUpload)
+	router.GET("rooms/:code/events", handleRoomEvents)
 
-	go startGame()
+	// Plain WebSocket alternative to Socket.IO, serving the same rooms
+	router.GET("ws", handleWebSocket)
 
 	router.GET("game/*any", gin.WrapH(socketIOServer))
 	router.POST("game/*any", gin.WrapH(socketIOServer))
@@ -89,128 +215,6 @@ func main() {
 	}
 }
 
-func handleJoinEvent(so socketio.Socket, playerName string) {
-	player := newPlayer(playerName)
-	game.join(player)
-
-	so.Emit("joined", SocketIOConnectedEvent{Game: game, Player: *player})
-
-	log.Printf("%v joined the game", player.Name)
-}
-
-func handleReconnectEvent(so socketio.Socket, playerID string) {
-	player, err := game.getPlayerByID(playerID)
-	log.Println(playerID)
-	if err != nil {
-		so.Emit("error", err)
-	}
-	so.Emit("joined", SocketIOConnectedEvent{Game: game, Player: *player})
-}
-
-func handleGuessEvent(so socketio.Socket, playerID, playerGuess string) {
-	player, err := game.getPlayerByID(playerID)
-
-	if err != nil {
-		so.Emit("err", err)
-	}
-
-	guess := newGuess(playerGuess, game.CurrentRound.Song)
-
-	log.Printf("Guess received from: %v. Guess: %v\n", player.ID, playerGuess)
-
-	if guess.artistGuessed() {
-		player.increaseScore(10)
-		so.Emit("artistGuessed", SocketIOArtistGuessedEvent{ArtistName: game.CurrentRound.Song.Artist.Name})
-		socketIOServer.BroadcastTo(
-			"gameRoom",
-			"update",
-			SocketIOUpdateEvent{Game: game},
-		)
-	}
-
-	if guess.songGuessed() {
-		player.increaseScore(10)
-		so.Emit(
-			"songGuessed",
-			SocketIOSongGuessedEvent{SongTitle: game.CurrentRound.Song.Title},
-		)
-		socketIOServer.BroadcastTo(
-			"gameRoom",
-			"update",
-			SocketIOUpdateEvent{Game: game},
-		)
-	}
-}
-
-func startGame() {
-	for {
-		roundNb := game.CurrentRound.Nb + 1
-		round := Round{
-			Nb:       roundNb,
-			Song:     playlist.getRandomSong(),
-			TimeLeft: 30,
-		}
-		game.CurrentRound = round
-		log.Printf("Round %v started. Song: %v - %v", game.CurrentRound.Nb, game.CurrentRound.Song.Title, game.CurrentRound.Song.Artist.Name)
-		// Send 'song' message with song details
-		socketIOServer.BroadcastTo(
-			"gameRoom",
-			"songStarted",
-			SocketIOSongStartedEvent{SongPreviewURI: game.CurrentRound.Song.Preview},
-		)
-
-		// Wait 30 sec (song preview duration)
-		game.CurrentRound.countdown()
-
-		// After 30sec, send artist + title
-		socketIOServer.BroadcastTo(
-			"gameRoom",
-			"response",
-			SocketIOResponseEvent{Song: game.CurrentRound.Song},
-		)
-		game.addSongToHistory(&game.CurrentRound.Song)
-
-		// Wait 10 sec before running new round
-		time.Sleep(10 * time.Second)
-
-		// TODO: Put nb of round into constant
-		if game.CurrentRound.Nb == 10 {
-			endGame()
-		}
-	}
-}
-
-func endGame() {
-	socketIOServer.BroadcastTo("gameRoom", "gameFinished", game.getLeaderBoard())
-	game.restart()
-}
-
-func getPlaylist(URI string) *Playlist {
-	var playlist Playlist
-
-	response, err := http.Get(URI)
-	if err != nil {
-		log.Printf("Can't get song. Err: %v", err.Error())
-		return nil
-	}
-
-	err = json.NewDecoder(response.Body).Decode(&playlist)
-	if err != nil {
-		log.Fatalf("Couldn't decode playlsit JSON. Err: %v", err)
-	}
-
-	if playlist.Next != "" {
-		tempPlaylist := getPlaylist(playlist.Next)
-
-		playlist.Songs = append(playlist.Songs, tempPlaylist.Songs...)
-	}
-
-	playlist.Songs = *filterSongsWithoutPreview(&playlist.Songs)
-	playlist.Length = len(playlist.Songs)
-
-	return &playlist
-}
-
 func filterSongsWithoutPreview(songs *[]Song) *[]Song {
 	tempSlice := make([]Song, 0)
 
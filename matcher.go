@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/hbakhtiyor/strsim"
+)
+
+// Minimum similarity between a guess and an answer for it to count
+const matchThreshold = 0.8
+
+// Tells whether a guess matches an accepted answer, both as typed
+type answerMatcher func(guess, answer string) bool
+
+// Ways of matching guesses, from the strictest
+var answerMatchers = map[string]answerMatcher{
+	"strict":   similarMatch,
+	"numbers":  numbersMatch,
+	"phonetic": phoneticMatch,
+}
+
+// Close enough character wise, ignoring case, accents and punctuation
+func similarMatch(guess, answer string) bool {
+	return strsim.Compare(sanitizeString(guess), sanitizeString(answer)) > matchThreshold
+}
+
+// Numbers can also be written with words, in English or French, or roman numerals
+func numbersMatch(guess, answer string) bool {
+	return similarMatch(guess, answer) || similarMatch(normalizeNumbers(guess), normalizeNumbers(answer))
+}
+
+// Misspellings sounding right count too. The most lenient, only used when the host picks it
+func phoneticMatch(guess, answer string) bool {
+	return numbersMatch(guess, answer) || soundsAlike(normalizeNumbers(guess), normalizeNumbers(answer))
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestAnswerMatchers(t *testing.T) {
+	tests := []struct {
+		guess, answer string
+		// Whether the guess matches, by matcher
+		strict, numbers, phonetic bool
+	}{
+		{"Bohemian Rhapsody", "Bohemian Rhapsody", true, true, true},
+		{"bohemian rapsody", "Bohemian Rhapsody", true, true, true},
+		{"Metalica", "Metallica", true, true, true},
+		{"99 problems", "Ninety-Nine Problems", false, true, true},
+		{"Rocky 4", "Rocky IV", false, true, true},
+		{"Quatre vingt dix neuf", "99", false, true, true},
+		{"Bionse", "Beyoncé", false, false, true},
+		{"Chakira", "Shakira", true, true, true},
+		{"Nirvanna", "Nirvana", true, true, true},
+		{"Jonny Kash", "Johnny Cash", false, false, true},
+		{"Fil Colins", "Phil Collins", false, false, true},
+		{"Steevie Wonder", "Stevie Wonder", true, true, true},
+		{"Édit Piaf", "Edith Piaf", false, false, true},
+		{"Jonny Aliday", "Johnny Hallyday", false, false, true},
+		{"Sharl Aznavour", "Charles Aznavour", false, false, true},
+		{"Bruno Mar", "Bruno Mars", true, true, true},
+		// Unrelated names sharing their consonants
+		{"Madonna", "Maiden", false, false, false},
+		{"Living", "Loving", false, false, false},
+		{"Blair", "Blur", false, false, false},
+		{"Prance", "Prince", false, false, false},
+		{"Sting", "Stang", false, false, false},
+		{"Police", "Place", false, false, false},
+		{"Toto", "Tata", false, false, false},
+		{"Beck", "Book", false, false, false},
+		{"Air", "Oar", false, false, false},
+		{"Korn", "Corona", false, false, false},
+		{"Abba", "Ebb", false, false, false},
+		{"Rocky 3", "Rocky IV", false, false, false},
+		// Same key, but spelled too differently
+		{"Rock", "Ruck", false, false, false},
+		{"Load", "Loud", false, false, false},
+		{"Cut", "Coat", false, false, false},
+	}
+
+	for _, test := range tests {
+		got := map[string]bool{
+			"strict":   answerMatchers["strict"](test.guess, test.answer),
+			"numbers":  answerMatchers["numbers"](test.guess, test.answer),
+			"phonetic": answerMatchers["phonetic"](test.guess, test.answer),
+		}
+		want := map[string]bool{"strict": test.strict, "numbers": test.numbers, "phonetic": test.phonetic}
+
+		for matcher, matches := range want {
+			if got[matcher] != matches {
+				t.Errorf("%v matching of %q against %q gave %v, expected %v", matcher, test.guess, test.answer, got[matcher], matches)
+			}
+		}
+	}
+}
+
+func TestPhoneticKey(t *testing.T) {
+	tests := map[string]string{
+		"Beyoncé":         "bens",
+		"Bionse":          "bens",
+		"Shakira":         "ksakera",
+		"Phil Collins":    "fel kolen",
+		"Knight":          "net",
+		"Françoise Hardy": "fransose arde",
+		"Madonna":         "madona",
+		"Maiden":          "meden",
+		"Blur":            "blor",
+		"Blair":           "bler",
+	}
+
+	for in, want := range tests {
+		if got := phoneticKey(in); got != want {
+			t.Errorf("phoneticKey(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
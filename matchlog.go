@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Types of match log entries
+const (
+	logMatchStarted = "match_started"
+	logJoin         = "join"
+	logLeave        = "leave"
+	logRoundStarted = "round_started"
+	logHint         = "hint"
+	logGuess        = "guess"
+	logChoice       = "choice"
+	logBuzz         = "buzz"
+	logBuzzTimeout  = "buzz_timeout"
+	logScore        = "score"
+	logRoundEnded   = "round_ended"
+	logMatchEnded   = "match_ended"
+)
+
+// A line of a match log. Only the fields relevant to the entry type are set.
+type MatchLogEntry struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Round      int       `json:"round,omitempty"`
+	PlayerID   string    `json:"player_id,omitempty"`
+	PlayerName string    `json:"player_name,omitempty"`
+	// Seconds left in the round, when the player acted
+	TimeLeft int `json:"time_left,omitempty"`
+	// Guesses, as typed and sanitized, with the best similarity to an answer not found
+	Guess      string   `json:"guess,omitempty"`
+	Sanitized  string   `json:"sanitized,omitempty"`
+	Similarity float64  `json:"similarity,omitempty"`
+	Outcomes   []string `json:"outcomes,omitempty"`
+	Option     *int     `json:"option,omitempty"`
+	// Score changes
+	Field  string `json:"field,omitempty"`
+	Points int    `json:"points,omitempty"`
+	Score  int    `json:"score,omitempty"`
+	// Round starts
+	RoundType string `json:"round_type,omitempty"`
+	Song      *Song  `json:"song,omitempty"`
+	Choices   []Song `json:"choices,omitempty"`
+	Hints     int    `json:"hints,omitempty"`
+	// Match starts and ends
+	Settings    *GameSettings `json:"settings,omitempty"`
+	Players     []Player      `json:"players,omitempty"`
+	Teams       []Team        `json:"teams,omitempty"`
+	LeaderBoard []Player      `json:"leaderboard,omitempty"`
+}
+
+// MatchLog appends the events of a match to a JSON Lines file
+type MatchLog struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newMatchLog(dir, code string, startedAt time.Time) (*MatchLog, error) {
+	name := fmt.Sprintf("%v-%v.jsonl", url.PathEscape(code), startedAt.UTC().Format("20060102-150405"))
+
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MatchLog{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (m *MatchLog) write(entry MatchLogEntry) error {
+	return m.encoder.Encode(entry)
+}
+
+func (m *MatchLog) close() error {
+	return m.file.Close()
+}
+
+// Starts logging the match, starting with its settings and players.
+// Scores are the starting ones, which aren't 0 for a match resumed after a restart.
+func (r *Room) openMatchLog() {
+	if r.matchLogDir == "" {
+		return
+	}
+	r.closeMatchLog()
+
+	matchLog, err := newMatchLog(r.matchLogDir, r.Code, r.Game.clock.Now())
+	if err != nil {
+		log.Printf("[%v] Can't open match log. Err: %v", r.Code, err)
+		return
+	}
+	r.matchLog = matchLog
+
+	settings := r.Game.Settings
+	r.record(MatchLogEntry{
+		Type:     logMatchStarted,
+		Round:    r.Game.CurrentRound.Nb,
+		Settings: &settings,
+		Players:  copyPlayers(r.Game.Players),
+		Teams:    copyTeams(r.Game.Teams),
+	})
+}
+
+// Appends entry to the match log, if the match is logged
+func (r *Room) record(entry MatchLogEntry) {
+	if r.matchLog == nil {
+		return
+	}
+
+	entry.Time = r.Game.clock.Now()
+	if entry.Round == 0 {
+		entry.Round = r.Game.CurrentRound.Nb
+	}
+
+	if err := r.matchLog.write(entry); err != nil {
+		log.Printf("[%v] Can't write match log. Err: %v", r.Code, err)
+	}
+}
+
+func (r *Room) closeMatchLog() {
+	if r.matchLog == nil {
+		return
+	}
+
+	if err := r.matchLog.close(); err != nil {
+		log.Printf("[%v] Can't close match log. Err: %v", r.Code, err)
+	}
+	r.matchLog = nil
+}
+
+func copyPlayers(players []*Player) []Player {
+	copied := make([]Player, len(players))
+	for i, player := range players {
+		copied[i] = *player
+	}
+	return copied
+}
+
+func copyTeams(teams []*Team) []Team {
+	copied := make([]Team, len(teams))
+	for i, team := range teams {
+		copied[i] = *team
+	}
+	return copied
+}
@@ -2,6 +2,9 @@ package main
 
 import "github.com/gin-gonic/gin"
 
+// Context key of the Origin header, which the middleware removes from the request
+const originContextKey = "origin"
+
 func GinMiddleware(allowOrigin string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", allowOrigin)
@@ -14,6 +17,8 @@ func GinMiddleware(allowOrigin string) gin.HandlerFunc {
 			return
 		}
 
+		// Kept for the handlers checking it themselves, like the WebSocket upgrade
+		c.Set(originContextKey, c.Request.Header.Get("Origin"))
 		c.Request.Header.Del("Origin")
 
 		c.Next()
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// '(Remastered 2015)', '[Live]', ...
+	bracketRegexp = regexp.MustCompile(`\s*[(\[{][^)\]}]*[)\]}]`)
+	// Featured artists credited in brackets, e.g. '(feat. Pharrell Williams)'
+	bracketCreditRegexp = regexp.MustCompile(`(?i)[(\[{]\s*(?:feat\.?|ft\.?|featuring|with)\s+([^)\]}]+)[)\]}]`)
+	// Featured artists credited after the title or main artist
+	creditRegexp = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring)\s+.*$`)
+	// Version suffixes, e.g. ' - Live' or ' - 2011 Remaster'
+	versionRegexp = regexp.MustCompile(`(?i)\s+-\s+.*\b(?:live|remaster(?:ed)?|edit|version|mix|remix|mono|stereo|acoustic|demo|single)\b.*$`)
+	// Separators between the artists credited for a song. Slashes and semicolons only count with
+	// spaces around them, as some names have one, e.g. 'AC/DC'.
+	artistSeparatorRegexp = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring|with|vs\.?|x|[/;])\s+`)
+	articleRegexp         = regexp.MustCompile(`(?i)^(?:the|an?|les?|la)\s+|^l'\s*`)
+)
+
+// Strips what players aren't expected to type from a title: brackets, featured artists,
+// version suffixes and leading article
+func normalizeTitle(title string) string {
+	title = bracketRegexp.ReplaceAllString(title, "")
+	title = creditRegexp.ReplaceAllString(title, "")
+	title = versionRegexp.ReplaceAllString(title, "")
+
+	return stripArticle(title)
+}
+
+func stripArticle(s string) string {
+	return strings.TrimSpace(articleRegexp.ReplaceAllString(strings.TrimSpace(s), ""))
+}
+
+// Returns the accepted answers for the title
+func titleAnswers(song Song) []string {
+	return uniqueAnswers(song.Title, normalizeTitle(song.Title))
+}
+
+// Returns the accepted answers for the artist: the full credit, and every artist credited,
+// including the ones featured in the title
+func artistAnswers(song Song) []string {
+	answers := []string{song.Artist.Name}
+
+	credits := artistSeparatorRegexp.Split(song.Artist.Name, -1)
+	for _, match := range bracketCreditRegexp.FindAllStringSubmatch(song.Title, -1) {
+		credits = append(credits, artistSeparatorRegexp.Split(match[1], -1)...)
+	}
+	if match := creditRegexp.FindString(song.Title); match != "" {
+		credits = append(credits, artistSeparatorRegexp.Split(match, -1)[1:]...)
+	}
+
+	for _, credit := range credits {
+		answers = append(answers, credit, stripArticle(credit))
+	}
+
+	return uniqueAnswers(answers...)
+}
+
+// Returns the first artist credited, e.g. 'Daft Punk' for 'Daft Punk feat. Pharrell Williams'
+func mainArtist(song Song) string {
+	return strings.TrimSpace(artistSeparatorRegexp.Split(song.Artist.Name, -1)[0])
+}
+
+// Drops empty answers, and the ones only differing by case, accents or punctuation
+func uniqueAnswers(answers ...string) []string {
+	unique := make([]string, 0, len(answers))
+	seen := make(map[string]bool)
+
+	for _, answer := range answers {
+		key := sanitizeString(answer)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, answer)
+	}
+
+	return unique
+}
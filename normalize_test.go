@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := map[string]string{
+		"Bohemian Rhapsody":                       "Bohemian Rhapsody",
+		"Bohemian Rhapsody (Remastered 2011)":     "Bohemian Rhapsody",
+		"Get Lucky [Radio Edit]":                  "Get Lucky",
+		"Get Lucky (feat. Pharrell Williams)":     "Get Lucky",
+		"Stay With Me feat. Mary J. Blige":        "Stay With Me",
+		"Wish You Were Here - 2011 Remaster":      "Wish You Were Here",
+		"Hallelujah - Live":                       "Hallelujah",
+		"The Final Countdown":                     "Final Countdown",
+		"L'Aventurier":                            "Aventurier",
+		"Les Champs-Élysées":                      "Champs-Élysées",
+		"A Day in the Life":                       "Day in the Life",
+		"Theme From New York":                     "Theme From New York",
+		"Another One Bites the Dust - Single Mix": "Another One Bites the Dust",
+	}
+
+	for title, want := range tests {
+		if got := normalizeTitle(title); got != want {
+			t.Errorf("normalizeTitle(%q) = %q, expected %q", title, got, want)
+		}
+	}
+}
+
+func TestTitleAnswers(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{"Yesterday", []string{"Yesterday"}},
+		{"The Wall (Live)", []string{"The Wall (Live)", "Wall"}},
+		// Only differing by punctuation
+		{"Help!", []string{"Help!"}},
+	}
+
+	for _, test := range tests {
+		if got := titleAnswers(Song{Title: test.title}); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("titleAnswers(%q) = %q, expected %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestArtistAnswers(t *testing.T) {
+	tests := []struct {
+		artist string
+		title  string
+		want   []string
+	}{
+		{"Adele", "Hello", []string{"Adele"}},
+		{"The Beatles", "Help!", []string{"The Beatles", "Beatles"}},
+		{"Daft Punk feat. Pharrell Williams", "Get Lucky", []string{"Daft Punk feat. Pharrell Williams", "Daft Punk", "Pharrell Williams"}},
+		{"David Guetta", "Titanium (feat. Sia)", []string{"David Guetta", "Sia"}},
+		{"Calvin Harris", "Summer ft. Ellie Goulding", []string{"Calvin Harris", "Ellie Goulding"}},
+		{"Simon & Garfunkel", "The Boxer", []string{"Simon & Garfunkel"}},
+		// Slashes within a name
+		{"AC/DC", "Thunderstruck", []string{"AC/DC"}},
+		{"Ferrante/Teicher", "Exodus", []string{"Ferrante/Teicher"}},
+		// Several artists, as read from multi-valued tags
+		{"Daft Punk / Pharrell Williams", "Get Lucky", []string{"Daft Punk / Pharrell Williams", "Daft Punk", "Pharrell Williams"}},
+		{"Queen ; David Bowie", "Under Pressure", []string{"Queen ; David Bowie", "Queen", "David Bowie"}},
+		{"AC/DC / The Beatles", "Medley", []string{"AC/DC / The Beatles", "AC/DC", "The Beatles", "Beatles"}},
+		{"Armin van Buuren vs. Sophie Ellis-Bextor", "Not Giving Up on Love", []string{"Armin van Buuren vs. Sophie Ellis-Bextor", "Armin van Buuren", "Sophie Ellis-Bextor"}},
+	}
+
+	for _, test := range tests {
+		song := Song{Title: test.title, Artist: Artist{Name: test.artist}}
+		if got := artistAnswers(song); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("artistAnswers(%q, %q) = %q, expected %q", test.artist, test.title, got, test.want)
+		}
+	}
+}
+
+func TestUniqueAnswers(t *testing.T) {
+	got := uniqueAnswers("Beyoncé", "", "beyonce", "BEYONCÉ!", "Jay-Z", "  ")
+	want := []string{"Beyoncé", "Jay-Z"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueAnswers() = %q, expected %q", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Number words, in English and French
+var numberWords = map[string]int{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8,
+	"nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+	"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19, "twenty": 20, "thirty": 30,
+	"forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	"hundred": 100, "thousand": 1000,
+	"un": 1, "une": 1, "deux": 2, "trois": 3, "quatre": 4, "cinq": 5, "sept": 7, "huit": 8,
+	"neuf": 9, "dix": 10, "onze": 11, "douze": 12, "treize": 13, "quatorze": 14, "quinze": 15,
+	"seize": 16, "vingt": 20, "vingts": 20, "trente": 30, "quarante": 40, "cinquante": 50,
+	"soixante": 60, "cent": 100, "cents": 100, "mille": 1000,
+}
+
+// Roman numerals are only read from 2, 'I' being a word too, to 50, higher ones looking like words
+var romanNumerals = buildRomanNumerals(2, 50)
+
+func buildRomanNumerals(min, max int) map[string]int {
+	symbols := []struct {
+		value  int
+		symbol string
+	}{{50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"}}
+
+	numerals := make(map[string]int)
+	for n := min; n <= max; n++ {
+		var numeral strings.Builder
+		for rest, i := n, 0; rest > 0; {
+			if rest >= symbols[i].value {
+				numeral.WriteString(symbols[i].symbol)
+				rest -= symbols[i].value
+			} else {
+				i++
+			}
+		}
+		numerals[numeral.String()] = n
+	}
+
+	return numerals
+}
+
+// Rewrites numbers written in words or roman numerals with digits, e.g. "ninety nine problems"
+// and "quatre-vingt-dix-neuf problems" both give "99 problems". Punctuation is dropped.
+func normalizeNumbers(s string) string {
+	words := strings.FieldsFunc(removeAccents(strings.ToLower(s)), func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9')
+	})
+
+	normalized := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		if _, ok := numberWords[words[i]]; ok {
+			number, next := readNumberWords(words, i)
+			normalized = append(normalized, strconv.Itoa(number))
+			i = next
+			continue
+		}
+
+		if number, ok := romanNumerals[words[i]]; ok {
+			normalized = append(normalized, strconv.Itoa(number))
+		} else {
+			normalized = append(normalized, words[i])
+		}
+		i++
+	}
+
+	return strings.Join(normalized, " ")
+}
+
+// Reads the number written by the words starting at start.
+// Returns it, and the index of the first word after it.
+func readNumberWords(words []string, start int) (int, int) {
+	total, current := 0, 0
+
+	i := start
+	for ; i < len(words); i++ {
+		word := words[i]
+
+		// 'vingt et un', 'one hundred and five'
+		if (word == "et" || word == "and") && i > start && i+1 < len(words) {
+			if _, ok := numberWords[words[i+1]]; ok {
+				continue
+			}
+		}
+
+		value, ok := numberWords[word]
+		if !ok {
+			break
+		}
+
+		switch {
+		case value == 0:
+			if i == start {
+				i++
+			}
+			return 0, i
+		case value == 1000:
+			total += maxInt(current, 1) * 1000
+			current = 0
+		case value == 100:
+			current = current - current%100 + maxInt(current%100, 1)*100
+		// 'quatre-vingt'
+		case value == 20 && current%100 == 4 && words[i-1] == "quatre":
+			current += 76
+		case value >= 20:
+			if current%100 != 0 {
+				return total + current, i
+			}
+			current += value
+		case value >= 10:
+			// 'soixante-dix', 'quatre-vingt-onze'
+			if tens := current % 100; tens != 0 && tens != 60 && tens != 80 {
+				return total + current, i
+			}
+			current += value
+		default:
+			if units := current % 100; units%10 != 0 || units > 10 && units < 20 {
+				return total + current, i
+			}
+			current += value
+		}
+	}
+
+	return total + current, i
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
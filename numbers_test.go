@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestNormalizeNumbers(t *testing.T) {
+	tests := map[string]string{
+		"99 Problems":                   "99 problems",
+		"Ninety-Nine Problems":          "99 problems",
+		"ninety nine problems":          "99 problems",
+		"Quatre-vingt-dix-neuf":         "99",
+		"Soixante-dix":                  "70",
+		"Vingt et un":                   "21",
+		"Quatre-vingts":                 "80",
+		"One Hundred and Five":          "105",
+		"Deux mille":                    "2000",
+		"Nineteen eighty-four":          "19 84",
+		"Two Thousand and One":          "2001",
+		"Zero Zero":                     "0 0",
+		"Rocky IV":                      "rocky 4",
+		"Final Fantasy XII":             "final fantasy 12",
+		"I Want You":                    "i want you",
+		"Mambo No. 5 (A Little Bit of)": "mambo no 5 a little bit of",
+		"Seven Nation Army":             "7 nation army",
+		"Cinq sept neuf":                "5 7 9",
+		"Élément":                       "element",
+	}
+
+	for in, want := range tests {
+		if got := normalizeNumbers(in); got != want {
+			t.Errorf("normalizeNumbers(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+func TestBuildRomanNumerals(t *testing.T) {
+	numerals := buildRomanNumerals(2, 50)
+
+	tests := map[string]int{"ii": 2, "iv": 4, "ix": 9, "xiv": 14, "xl": 40, "xlix": 49, "l": 50}
+	for numeral, want := range tests {
+		if got, ok := numerals[numeral]; !ok || got != want {
+			t.Errorf("%v = %v, expected %v", numeral, got, want)
+		}
+	}
+
+	for _, numeral := range []string{"i", "li", "iiii"} {
+		if _, ok := numerals[numeral]; ok {
+			t.Errorf("%v shouldn't be read as a number", numeral)
+		}
+	}
+	if len(numerals) != 49 {
+		t.Errorf("%v numerals, expected 49", len(numerals))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/hbakhtiyor/strsim"
+)
+
+// Spelling rules bringing words that sound alike to the same spelling, for English and French.
+// Order matters: digraphs are rewritten before the letters they contain.
+var phoneticRules = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`sch|ch|sh`), "x"},
+	{regexp.MustCompile(`ph`), "f"},
+	{regexp.MustCompile(`th`), "t"},
+	{regexp.MustCompile(`gh`), ""},
+	{regexp.MustCompile(`^kn`), "n"},
+	{regexp.MustCompile(`^wr`), "r"},
+	{regexp.MustCompile(`^ps`), "s"},
+	{regexp.MustCompile(`mb$`), "m"},
+	{regexp.MustCompile(`ck|qu|q`), "k"},
+	{regexp.MustCompile(`c([eiy])`), "s$1"},
+	{regexp.MustCompile(`c`), "k"},
+	{regexp.MustCompile(`gu([eiy])`), "g$1"},
+	{regexp.MustCompile(`g([eiy])`), "j$1"},
+	{regexp.MustCompile(`gn`), "n"},
+	{regexp.MustCompile(`x`), "ks"},
+	{regexp.MustCompile(`z`), "s"},
+	{regexp.MustCompile(`h`), ""},
+	// Vowel digraphs
+	{regexp.MustCompile(`eau|au`), "o"},
+	{regexp.MustCompile(`ou|oo`), "u"},
+	{regexp.MustCompile(`ai|ei|ay|ey`), "e"},
+	{regexp.MustCompile(`ee|ea|ie`), "i"},
+	// Final e is silent after two consonants, e.g. 'Charles', but lengthens a vowel before one
+	{regexp.MustCompile(`([^aeiouy]{2})e$`), "$1"},
+}
+
+// Vowels sounding alike, a run of vowels sounding like its first one
+var phoneticVowelClasses = map[byte]byte{'a': 'a', 'e': 'e', 'i': 'e', 'y': 'e', 'o': 'o', 'u': 'o'}
+
+const (
+	// Shorter keys are too ambiguous to match on
+	minPhoneticKeyLength = 3
+	// Minimum similarity between the respelled words, for words only alike once reduced to their key
+	phoneticSimilarityFloor = 0.4
+)
+
+// Respells every word of s by the phonetic rules
+func phoneticWords(s string) []string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ç", "s")
+	words := strings.FieldsFunc(removeAccents(s), func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9')
+	})
+
+	for i, word := range words {
+		// Plural marks and French final s and x are silent
+		if len(word) > 3 && strings.ContainsAny(word[len(word)-1:], "sx") {
+			word = word[:len(word)-1]
+		}
+
+		for _, rule := range phoneticRules {
+			word = rule.pattern.ReplaceAllString(word, rule.replacement)
+		}
+		words[i] = word
+	}
+
+	return words
+}
+
+// Encodes every word of s to its consonants and vowel classes, e.g. "Beyoncé" and "Bionse" both
+// give "bens"
+func phoneticKey(s string) string {
+	words := phoneticWords(s)
+
+	keys := make([]string, len(words))
+	for i, word := range words {
+		keys[i] = phoneticWordKey(word)
+	}
+
+	return strings.Join(keys, " ")
+}
+
+func phoneticWordKey(word string) string {
+	key := make([]byte, 0, len(word))
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		// Doubled letters sound single
+		if i > 0 && c == word[i-1] {
+			continue
+		}
+		if class, ok := phoneticVowelClasses[c]; ok {
+			if i > 0 && isPhoneticVowel(word[i-1]) {
+				continue
+			}
+			c = class
+		}
+		key = append(key, c)
+	}
+
+	return string(key)
+}
+
+func isPhoneticVowel(c byte) bool {
+	_, ok := phoneticVowelClasses[c]
+	return ok
+}
+
+// Whether a and b sound alike
+func soundsAlike(a, b string) bool {
+	keyA, keyB := phoneticKey(a), phoneticKey(b)
+	if keyA != keyB || len(strings.ReplaceAll(keyA, " ", "")) < minPhoneticKeyLength {
+		return false
+	}
+
+	respelledA := strings.Join(phoneticWords(a), "")
+	respelledB := strings.Join(phoneticWords(b), "")
+	return strsim.Compare(respelledA, respelledB) >= phoneticSimilarityFloor
+}
@@ -0,0 +1,159 @@
+package main
+
+// SongPicker draws the songs of a match
+type SongPicker interface {
+	// Prepares the picker for a new match, drawing from songs
+	reset(songs []Song)
+	// Draws the next song. Songs are not drawn twice until every song has been drawn
+	next(random Random) Song
+}
+
+var songPickers = map[string]func() SongPicker{
+	"shuffle": func() SongPicker {
+		return newWeightedPicker(nil)
+	},
+	// Popular songs come up more often
+	"popularity": func() SongPicker {
+		return newWeightedPicker(popularityWeights)
+	},
+	// Every decade is equally likely to come up, however many songs it has
+	"decades": func() SongPicker {
+		return newWeightedPicker(decadeWeights)
+	},
+}
+
+// Weights of songs, by index
+type songWeights func(songs []Song) []float64
+
+// Draws songs without replacement, with a probability proportional to their weight.
+// Without weights it is a plain shuffle bag.
+type weightedPicker struct {
+	weigh   songWeights
+	songs   []Song
+	bag     []Song
+	weights []float64
+}
+
+func newWeightedPicker(weigh songWeights) *weightedPicker {
+	return &weightedPicker{weigh: weigh}
+}
+
+func (p *weightedPicker) reset(songs []Song) {
+	p.songs = songs
+	p.refill()
+}
+
+func (p *weightedPicker) refill() {
+	p.bag = make([]Song, len(p.songs))
+	copy(p.bag, p.songs)
+
+	if p.weigh != nil {
+		p.weights = p.weigh(p.bag)
+	}
+}
+
+func (p *weightedPicker) next(random Random) Song {
+	// Every song has been drawn, start over
+	if len(p.bag) == 0 {
+		p.refill()
+	}
+
+	idx := random.Intn(len(p.bag))
+	if p.weigh != nil {
+		idx = weightedIndex(p.weights, random)
+	}
+
+	song := p.bag[idx]
+
+	// Swap remove the drawn song
+	last := len(p.bag) - 1
+	p.bag[idx] = p.bag[last]
+	p.bag = p.bag[:last]
+	if p.weigh != nil {
+		p.weights[idx] = p.weights[last]
+		p.weights = p.weights[:last]
+	}
+
+	return song
+}
+
+func weightedIndex(weights []float64, random Random) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	target := random.Float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return i
+		}
+	}
+
+	return len(weights) - 1
+}
+
+// From 1 for the least popular song to 10 for the most popular one
+func popularityWeights(songs []Song) []float64 {
+	maxPopularity := 0
+	for _, song := range songs {
+		if song.Popularity > maxPopularity {
+			maxPopularity = song.Popularity
+		}
+	}
+
+	weights := make([]float64, len(songs))
+	for i, song := range songs {
+		weights[i] = 1
+		if maxPopularity > 0 {
+			weights[i] += 9 * float64(song.Popularity) / float64(maxPopularity)
+		}
+	}
+
+	return weights
+}
+
+// Songs of crowded decades weigh less. Songs without year make up a decade of their own
+func decadeWeights(songs []Song) []float64 {
+	counts := make(map[int]int)
+	for _, song := range songs {
+		counts[song.Year/10]++
+	}
+
+	weights := make([]float64, len(songs))
+	for i, song := range songs {
+		weights[i] = 1 / float64(counts[song.Year/10])
+	}
+
+	return weights
+}
+
+// Returns the playlist songs that were not played during the recent matches, or every song
+// if there would not be enough of them left for a whole match
+func (g *Game) selectableSongs(songs []Song) []Song {
+	recentMatches := g.Settings.AvoidRecentMatches
+	if recentMatches > len(g.recentMatches) {
+		recentMatches = len(g.recentMatches)
+	}
+
+	played := make(map[string]bool)
+	for _, match := range g.recentMatches[len(g.recentMatches)-recentMatches:] {
+		for _, id := range match {
+			played[id] = true
+		}
+	}
+
+	selectable := make([]Song, 0)
+	for _, song := range songs {
+		if !played[song.ID] {
+			selectable = append(selectable, song)
+		}
+	}
+
+	if len(selectable) < g.Settings.Rounds {
+		return songs
+	}
+
+	return selectable
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/satori/go.uuid"
+)
+
+// Player whose replayed score differs from the broadcast one
+type ScoreDiff struct {
+	PlayerID   string
+	PlayerName string
+	Broadcast  int
+	Replayed   int
+}
+
+type MatchReplay struct {
+	LeaderBoard []*Player
+	// Nil if the log has no end, the match having been interrupted
+	Broadcast []Player
+	Diffs     []ScoreDiff
+}
+
+// Plays as a player of a replayed room, whose events nobody listens to
+type replayClient struct {
+	playerID string
+}
+
+func (c replayClient) Id() string {
+	return c.playerID
+}
+
+func (c replayClient) Emit(message string, args ...interface{}) error {
+	return nil
+}
+
+// Feeds a match log back through the game logic, rebuilding the leaderboard from the recorded
+// guesses alone. Scores logged along the way are ignored, only the final broadcast one is compared.
+func replayMatchLog(reader io.Reader) (*MatchReplay, error) {
+	var room *Room
+	var clock *FakeClock
+	replay := &MatchReplay{}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry MatchLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("Line %v: %v", line, err)
+		}
+
+		if room == nil {
+			if entry.Type != logMatchStarted {
+				return nil, errors.New("Match log doesn't start with the match start")
+			}
+
+			clock = newFakeClock(entry.Time)
+			room = newReplayRoom(entry, clock)
+			continue
+		}
+
+		clock.Advance(entry.Time.Sub(clock.Now()))
+		round := &room.Game.CurrentRound
+		client := replayClient{playerID: entry.PlayerID}
+
+		switch entry.Type {
+		case logJoin:
+			id, err := uuid.FromString(entry.PlayerID)
+			if err != nil {
+				return nil, fmt.Errorf("Line %v: %v", line, err)
+			}
+			player := &Player{ID: id, Name: entry.PlayerName}
+			room.Game.join(player)
+			room.addClient(client, player)
+		case logLeave:
+			if player, err := room.Game.getPlayerByID(entry.PlayerID); err == nil {
+				room.Game.leave(player)
+				room.removeClient(client)
+			}
+		case logRoundStarted:
+			room.Game.CurrentRound = Round{
+				Nb:        entry.Round,
+				Type:      entry.RoundType,
+				TimeLeft:  entry.TimeLeft,
+				StartedAt: entry.Time,
+				Scores:    make([]*RoundScore, 0),
+				choices:   entry.Choices,
+			}
+			if entry.Song != nil {
+				room.Game.CurrentRound.Song = *entry.Song
+			}
+			room.phase = guessPhase
+		case logHint:
+			round.Hints = entry.Hints
+		case logGuess:
+			round.TimeLeft = entry.TimeLeft
+			guessCommand{client: client, guess: entry.Guess}.apply(room)
+		case logChoice:
+			if entry.Option == nil {
+				return nil, fmt.Errorf("Line %v: choice without option", line)
+			}
+			round.TimeLeft = entry.TimeLeft
+			chooseCommand{client: client, option: *entry.Option}.apply(room)
+		case logBuzz:
+			round.TimeLeft = entry.TimeLeft
+			buzzCommand{client: client}.apply(room)
+		case logBuzzTimeout:
+			if round.buzzer != nil {
+				room.releaseBuzz(false)
+			}
+		case logRoundEnded:
+			// A right buzzer answer already ended the round
+			if room.phase == guessPhase {
+				room.phase = revealPhase
+			}
+		case logMatchEnded:
+			replay.Broadcast = entry.LeaderBoard
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if room == nil {
+		return nil, errors.New("Match log is empty")
+	}
+
+	replay.LeaderBoard = *room.Game.getLeaderBoard()
+	replay.Diffs = diffLeaderBoards(replay.Broadcast, replay.LeaderBoard)
+
+	return replay, nil
+}
+
+// Builds a room in the state the match started in
+func newReplayRoom(entry MatchLogEntry, clock *FakeClock) *Room {
+	room := newRoom("replay", newStaticSource(nil), Playlist{}, clock, newRandom(0))
+
+	for i := range entry.Players {
+		player := entry.Players[i]
+		room.Game.join(&player)
+		room.addClient(replayClient{playerID: player.ID.String()}, &player)
+	}
+	for i := range entry.Teams {
+		team := entry.Teams[i]
+		room.Game.Teams = append(room.Game.Teams, &team)
+	}
+	if entry.Settings != nil {
+		room.Game.Settings = *entry.Settings
+	}
+	room.Game.Started = true
+	room.Game.CurrentRound = Round{Nb: entry.Round}
+	room.phase = revealPhase
+	// Songs come from the log, the room has no playlist to draw from. Seen as already preparing
+	// one, revealing a round on a right buzzer answer doesn't draw the next.
+	room.preparing = true
+
+	return room
+}
+
+func diffLeaderBoards(broadcast []Player, replayed []*Player) []ScoreDiff {
+	diffs := make([]ScoreDiff, 0)
+	if broadcast == nil {
+		return diffs
+	}
+
+	scores := make(map[string]*Player)
+	for _, player := range replayed {
+		scores[player.ID.String()] = player
+	}
+
+	for _, player := range broadcast {
+		diff := ScoreDiff{PlayerID: player.ID.String(), PlayerName: player.Name, Broadcast: player.Score}
+		if replayedPlayer, ok := scores[diff.PlayerID]; ok {
+			diff.Replayed = replayedPlayer.Score
+			delete(scores, diff.PlayerID)
+		}
+		if diff.Replayed != diff.Broadcast {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	// Players the broadcast leaderboard doesn't know about
+	for _, player := range replayed {
+		if _, ok := scores[player.ID.String()]; ok && player.Score != 0 {
+			diffs = append(diffs, ScoreDiff{PlayerID: player.ID.String(), PlayerName: player.Name, Replayed: player.Score})
+		}
+	}
+
+	return diffs
+}
+
+// Replays the match log at path, printing the rebuilt leaderboard and how it differs from the
+// broadcast one. Returns the process exit code.
+func runReplay(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't open match log. Err: %v\n", err)
+		return 2
+	}
+	defer file.Close()
+
+	replay, err := replayMatchLog(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Can't replay match log. Err: %v\n", err)
+		return 2
+	}
+
+	fmt.Println("Replayed leaderboard:")
+	for i := len(replay.LeaderBoard) - 1; i >= 0; i-- {
+		player := replay.LeaderBoard[i]
+		fmt.Printf("  %v: %v\n", player.Name, player.Score)
+	}
+
+	if replay.Broadcast == nil {
+		fmt.Println("The match didn't end, nothing to compare with")
+		return 0
+	}
+
+	if len(replay.Diffs) == 0 {
+		fmt.Println("Matches the broadcast leaderboard")
+		return 0
+	}
+
+	fmt.Println("Differs from the broadcast leaderboard:")
+	for _, diff := range replay.Diffs {
+		fmt.Printf("  %v (%v): broadcast %v, replayed %v\n", diff.PlayerName, diff.PlayerID, diff.Broadcast, diff.Replayed)
+	}
+
+	return 1
+}
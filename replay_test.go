@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Plays a match where Alice finds every artist by its nickname, returning its log
+func playAliasedMatch(t *testing.T, dir, mode string) string {
+	songs := testSongs(6)
+	clock := newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	room := newRoom("test", newStaticSource(songs), Playlist{Songs: songs, Length: len(songs)}, clock, newRandom(1))
+	room.matchLogDir = dir
+	go room.run()
+
+	alice, bob := newRecordingClient("alice"), newRecordingClient("bob")
+	room.send(joinCommand{client: alice, playerName: "Alice"})
+	room.send(joinCommand{client: bob, playerName: "Bob"})
+	room.send(configureCommand{client: alice, params: map[string]interface{}{"mode": mode, "rounds": 3.0}})
+	room.send(startCommand{client: alice})
+
+	for nb := 1; nb <= 3; nb++ {
+		advanceUntil(t, room, clock, fmt.Sprintf("round %v", nb), func(r *Room) bool {
+			return r.phase == guessPhase && r.Game.CurrentRound.Nb == nb
+		})
+
+		var id string
+		probe(t, room, func(r *Room) {
+			id = r.Game.CurrentRound.Song.ID
+		})
+		if mode == buzzerMode {
+			// Bob's guess is refused, Alice holding the buzzer
+			room.send(buzzCommand{client: alice})
+		}
+		room.send(guessCommand{client: alice, guess: "Nickname " + id})
+		room.send(guessCommand{client: bob, guess: "Title " + id})
+
+		advanceUntil(t, room, clock, fmt.Sprintf("round %v reveal", nb), func(r *Room) bool {
+			return r.phase != guessPhase
+		})
+	}
+	advanceUntil(t, room, clock, "match end", func(r *Room) bool {
+		return r.phase == lobbyPhase
+	})
+	room.send(leaveCommand{client: alice})
+	room.send(leaveCommand{client: bob})
+	<-room.done
+
+	logs, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("Expected a match log, got %v, %v", logs, err)
+	}
+	return logs[0]
+}
+
+func TestReplayWithAliases(t *testing.T) {
+	defaultAliases := aliases
+	defer func() {
+		aliases = defaultAliases
+	}()
+
+	for _, mode := range []string{freeTextMode, buzzerMode} {
+		t.Run(mode, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "matchlog")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			aliases = newAliasDictionary("")
+			for i := 0; i < 6; i++ {
+				aliases.set(artistField, fmt.Sprintf("Artist %v", i), []string{fmt.Sprintf("Nickname %v", i)})
+			}
+			path := playAliasedMatch(t, dir, mode)
+
+			replay := func() *MatchReplay {
+				file, err := os.Open(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+
+				replay, err := replayMatchLog(file)
+				if err != nil {
+					t.Fatalf("Can't replay: %v", err)
+				}
+				return replay
+			}
+
+			if diffs := replay().Diffs; len(diffs) != 0 {
+				t.Errorf("Replay with the aliases differs: %+v", diffs)
+			}
+
+			// Without them, Alice's guesses are wrong
+			aliases = newAliasDictionary("")
+			diffs := replay().Diffs
+			if len(diffs) != 1 || diffs[0].PlayerName != "Alice" || diffs[0].Replayed != 0 {
+				t.Errorf("Replay without the aliases gave %+v, expected Alice to lose her points", diffs)
+			}
+		})
+	}
+}
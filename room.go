@@ -0,0 +1,555 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Client is a connection the room can send events to
+type Client interface {
+	Id() string
+	Emit(message string, args ...interface{}) error
+}
+
+// Seconds a room whose players all disconnected waits for one of them before closing
+const emptyRoomGrace = 60
+
+type roomPhase int
+
+const (
+	// Waiting for the host to start the match
+	lobbyPhase roomPhase = iota
+	// A song is playing, players can guess
+	guessPhase
+	// The answer is revealed, waiting for the next round
+	revealPhase
+	// Restored after a restart, waiting for a player to reconnect before the next round
+	resumePhase
+)
+
+// Room owns a game, which is only ever read and mutated by the room loop goroutine.
+// Everything else talks to the game by sending commands to the room.
+type Room struct {
+	Code     string
+	Game     Game
+	Source   SongSource
+	Playlist Playlist
+	// The playlist was imported by the host
+	imported bool
+	picker   SongPicker
+	// Connected clients, and the player they play as, by client id
+	clients map[string]Client
+	players map[string]*Player
+	phase   roomPhase
+	// Seconds left before the next round, during the reveal
+	revealLeft int
+	// Seconds left before the room closes, while no client is connected
+	emptyLeft int
+	// Song of the next round once resolved, which happens off the room loop
+	nextSong  *Song
+	preparing bool
+	// Round waiting for its song to start, 0 if none
+	waitingRound int
+	commands     chan command
+	done         chan struct{}
+	// Called by the room loop when the room closes, before it stops
+	onClose func(*Room)
+	// Where snapshots are saved, nil when rooms aren't persisted
+	store RoomStore
+	// Folder match logs are written to, empty when matches aren't logged
+	matchLogDir string
+	matchLog    *MatchLog
+	// Signs the session tokens of players, nil when the room issues none
+	sessions *SessionSigner
+	// Random id of this instance of the room, which tokens are only valid for
+	sessionID string
+	// Read-only events for spectators
+	feed *SpectatorFeed
+}
+
+func newRoom(code string, source SongSource, playlist Playlist, clock Clock, random Random) *Room {
+	return &Room{
+		Code:      code,
+		Game:      newGame(make([]*Player, 0), clock, random),
+		Source:    source,
+		Playlist:  playlist,
+		clients:   make(map[string]Client),
+		players:   make(map[string]*Player),
+		phase:     lobbyPhase,
+		commands:  make(chan command),
+		done:      make(chan struct{}),
+		sessionID: newSessionID(),
+		feed:      newSpectatorFeed(),
+	}
+}
+
+// Queues cmd for the room loop. Returns false if the room is closed
+func (r *Room) send(cmd command) bool {
+	select {
+	case r.commands <- cmd:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *Room) closed() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Room) run() {
+	ticker := r.Game.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for !r.closed() {
+		select {
+		case cmd := <-r.commands:
+			cmd.apply(r)
+		case <-ticker.C():
+			tickCommand{}.apply(r)
+		}
+	}
+
+	log.Printf("[%v] Game loop stopped", r.Code)
+}
+
+func (r *Room) broadcast(message string, args ...interface{}) {
+	for _, client := range r.clients {
+		client.Emit(message, args...)
+	}
+}
+
+func (r *Room) addClient(client Client, player *Player) {
+	r.clients[client.Id()] = client
+	r.players[client.Id()] = player
+	r.emptyLeft = 0
+
+	// A restored match goes on once someone is back
+	if r.phase == resumePhase {
+		r.phase = revealPhase
+		r.revealLeft = r.Game.Settings.RevealPause
+	}
+}
+
+func (r *Room) removeClient(client Client) {
+	delete(r.clients, client.Id())
+	delete(r.players, client.Id())
+
+	if len(r.clients) > 0 {
+		return
+	}
+
+	// Disconnected players get some time to reconnect, a page refresh shouldn't end the match
+	if len(r.Game.Players) > 0 {
+		r.emptyLeft = emptyRoomGrace
+		return
+	}
+	r.expire()
+}
+
+// The host is the longest-standing connected player. Players who disconnected stay in the game
+// until the room closes, so host rights pass on when the host leaves or loses their connection.
+func (r *Room) isHost(player *Player) bool {
+	for _, p := range r.Game.Players {
+		for _, connected := range r.players {
+			if connected.ID == p.ID {
+				return p.ID == player.ID
+			}
+		}
+	}
+	return false
+}
+
+// Counts the grace period of a room without client down, closing it once over
+func (r *Room) tickEmpty() {
+	if len(r.clients) > 0 || r.emptyLeft <= 0 {
+		return
+	}
+
+	r.emptyLeft--
+	if r.emptyLeft == 0 {
+		log.Printf("[%v] Nobody reconnected", r.Code)
+		r.expire()
+	}
+}
+
+// Closes the room for good, its players having left or not come back. The snapshot is deleted
+// first, so that neither a new room under the same code nor the session tokens of the players
+// bring it back: only a restart resumes a room from its snapshot.
+func (r *Room) expire() {
+	if r.store != nil {
+		if err := r.store.Delete(r.Code); err != nil {
+			log.Printf("[%v] Can't delete saved room. Err: %v", r.Code, err)
+		}
+	}
+	r.closeIfEmpty()
+}
+
+// Stops the room if no client is connected
+func (r *Room) closeIfEmpty() {
+	if len(r.clients) > 0 || r.closed() {
+		return
+	}
+
+	if r.onClose != nil {
+		r.onClose(r)
+	}
+	r.closeMatchLog()
+	r.feed.close()
+	close(r.done)
+
+	log.Printf("Room %v closed", r.Code)
+}
+
+func (r *Room) startMatch() {
+	r.Game.Started = true
+
+	r.picker = songPickers[r.Game.Settings.SongPicker]()
+	r.picker.reset(r.Game.selectableSongs(r.Playlist.Songs))
+	r.snapshot()
+	r.openMatchLog()
+
+	r.nextSong = nil
+	r.waitingRound = 1
+	r.startWaitingRound()
+}
+
+// Picks the song of the next round, and resolves it off the room loop, as sources may be slow
+// to answer. The room gets it back with a songResolvedCommand.
+func (r *Room) prepareSong() {
+	song := r.picker.next(r.Game.random)
+	source := r.Source
+	r.preparing = true
+
+	go func() {
+		resolved, err := source.Resolve(song)
+		if err != nil {
+			log.Printf("[%v] Can't resolve song, using the listed one. Err: %v", r.Code, err)
+			resolved = song
+		}
+
+		picture, err := source.ArtistPicture(resolved.Artist)
+		if err != nil {
+			log.Printf("[%v] Can't get artist picture. Err: %v", r.Code, err)
+		}
+		resolved.Artist.Picture = picture
+
+		r.send(songResolvedCommand{song: resolved})
+	}()
+}
+
+// Starts the waiting round if its song is ready, preparing it otherwise
+func (r *Room) startWaitingRound() {
+	if r.nextSong == nil {
+		if !r.preparing {
+			r.prepareSong()
+		}
+		return
+	}
+
+	nb := r.waitingRound
+	song := *r.nextSong
+	r.waitingRound = 0
+	r.nextSong = nil
+	r.startRound(nb, song)
+}
+
+func (r *Room) startRound(nb int, song Song) {
+	round := Round{
+		Nb:        nb,
+		Type:      r.Game.Settings.roundType(nb),
+		Song:      song,
+		TimeLeft:  r.Game.Settings.GuessTime,
+		StartedAt: r.Game.clock.Now(),
+		Scores:    make([]*RoundScore, 0),
+	}
+	if round.Type == yearRound && round.Song.Year == 0 {
+		log.Printf("[%v] Release year unknown, playing a song round instead", r.Code)
+		round.Type = songRound
+	}
+	if r.Game.Settings.Mode == multipleChoiceMode {
+		round.choices = pickChoices(round.Song, r.Playlist.Songs, r.Game.random)
+	}
+	r.Game.CurrentRound = round
+	r.phase = guessPhase
+	r.record(MatchLogEntry{
+		Type:      logRoundStarted,
+		RoundType: round.Type,
+		Song:      &round.Song,
+		Choices:   round.choices,
+		TimeLeft:  round.TimeLeft,
+	})
+
+	log.Printf("[%v] Round %v started. Song: %v - %v", r.Code, round.Nb, round.Song.Title, round.Song.Artist.Name)
+	// Send 'song' message with song details
+	r.broadcast(
+		"songStarted",
+		SocketIOSongStartedEvent{
+			SongPreviewURI: round.Song.Preview,
+			RoundType:      round.Type,
+			Choices:        newSocketIOChoices(round.choices),
+		},
+	)
+	r.feed.publish("roundStarted", FeedRoundStartedEvent{
+		Round:          round.Nb,
+		Rounds:         r.Game.Settings.Rounds,
+		RoundType:      round.Type,
+		SongPreviewURI: round.Song.Preview,
+		Choices:        newSocketIOChoices(round.choices),
+	})
+}
+
+// Ends the guessing part of the round, and sends artist + title
+func (r *Room) revealRound() {
+	r.phase = revealPhase
+	r.revealLeft = r.Game.Settings.RevealPause
+
+	r.broadcast(
+		"response",
+		SocketIOResponseEvent{Song: r.Game.CurrentRound.Song, Scores: newSocketIORoundScores(r.Game.CurrentRound.Scores)},
+	)
+	r.feed.publish("reveal", FeedRevealEvent{
+		Round:  r.Game.CurrentRound.Nb,
+		Song:   r.Game.CurrentRound.Song,
+		Scores: newSocketIORoundScores(r.Game.CurrentRound.Scores),
+	})
+	r.Game.addRoundToHistory(&r.Game.CurrentRound)
+	r.snapshot()
+	r.record(MatchLogEntry{Type: logRoundEnded, TimeLeft: r.Game.CurrentRound.TimeLeft})
+
+	// The next song is resolved during the pause
+	if r.Game.CurrentRound.Nb < r.Game.Settings.Rounds && !r.preparing && r.nextSong == nil {
+		r.prepareSong()
+	}
+}
+
+func (r *Room) nextRound() {
+	if r.Game.CurrentRound.Nb >= r.Game.Settings.Rounds {
+		r.endGame()
+		return
+	}
+
+	r.waitingRound = r.Game.CurrentRound.Nb + 1
+	r.startWaitingRound()
+}
+
+func (r *Room) endGame() {
+	leaderBoard := r.Game.getLeaderBoard()
+	r.broadcast("gameFinished", SocketIOGameFinishedEvent{
+		LeaderBoard: newSocketIOPlayers(*leaderBoard),
+		Teams:       r.Game.getTeamLeaderBoard(),
+	})
+	r.record(MatchLogEntry{Type: logMatchEnded, LeaderBoard: copyPlayers(*leaderBoard)})
+	r.closeMatchLog()
+	r.feed.publish("gameFinished", FeedLeaderBoardEvent{
+		LeaderBoard: newSocketIOPlayers(*leaderBoard),
+		Teams:       copyTeams(r.Game.getTeamLeaderBoard()),
+	})
+
+	r.Game.restart()
+	r.phase = lobbyPhase
+	r.snapshot()
+	r.publishLeaderBoard()
+}
+
+// Scores the player for finding field, and tells the room who found it
+func (r *Room) creditField(player *Player, field string, base int) {
+	round := &r.Game.CurrentRound
+	rank := round.foundCount(field) + 1
+
+	// Counted on the round countdown, which stands still while a buzzer answers
+	elapsed := r.Game.Settings.GuessTime - round.TimeLeft
+	award := r.Game.Settings.scoreAward(field, base, elapsed, rank, round.Hints)
+	player.increaseScore(award.total())
+	round.recordAward(player, award)
+	r.record(MatchLogEntry{
+		Type:       logScore,
+		PlayerID:   player.ID.String(),
+		PlayerName: player.Name,
+		Field:      field,
+		Points:     award.total(),
+		Score:      player.Score,
+	})
+	r.Game.updateTeams()
+	r.publishLeaderBoard()
+
+	if r.Game.Settings.SharedTeamCredit {
+		for _, teammate := range r.Game.teammates(player) {
+			round.markFound(teammate, field)
+		}
+	} else {
+		round.markFound(player, field)
+	}
+
+	r.broadcast(
+		"playerFound",
+		SocketIOPlayerFoundEvent{PlayerName: player.Name, Field: field, Rank: rank},
+	)
+}
+
+// Reveals the field the client found to them
+func (r *Room) tellFound(client Client, match GuessMatch) {
+	song := r.Game.CurrentRound.Song
+
+	switch match.Field {
+	case artistField:
+		// The picture was fetched along with the song
+		client.Emit("artistGuessed", SocketIOArtistGuessedEvent{ArtistName: song.Artist.Name, ArtistPictureURI: song.Artist.Picture})
+	case titleField:
+		client.Emit("songGuessed", SocketIOSongGuessedEvent{SongTitle: song.Title})
+	case yearField:
+		client.Emit("yearGuessed", SocketIOYearGuessedEvent{Year: song.Year, Points: match.Points})
+	}
+}
+
+type RoomRegistry struct {
+	mu sync.Mutex
+	// Catalog new rooms draw their playlist from
+	source SongSource
+	// Nil when rooms aren't persisted
+	store RoomStore
+	// Empty when matches aren't logged
+	matchLogDir string
+	sessions    *SessionSigner
+	clock       Clock
+	rooms       map[string]*Room
+}
+
+func newRoomRegistry(source SongSource, store RoomStore, matchLogDir string, sessions *SessionSigner, clock Clock) *RoomRegistry {
+	return &RoomRegistry{
+		source:      source,
+		store:       store,
+		matchLogDir: matchLogDir,
+		sessions:    sessions,
+		clock:       clock,
+		rooms:       make(map[string]*Room),
+	}
+}
+
+// Returns the room matching code, restoring or creating it (and starting its game loop) if needed
+func (rr *RoomRegistry) getOrCreate(code string) (*Room, error) {
+	if room, err := rr.getOrRestore(code); err == nil {
+		return room, nil
+	}
+
+	// Loading the playlist may take a while, other rooms aren't held meanwhile
+	playlist, err := newPlaylist(rr.source)
+	if err != nil {
+		log.Printf("Couldn't load playlist. Err: %v", err)
+		return nil, errors.New("Couldn't load playlist")
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	// Someone else may have created the room meanwhile
+	if room, err := rr.getOrRestoreLocked(code); err == nil {
+		return room, nil
+	}
+
+	random := newRandom(rr.clock.Now().UnixNano())
+	room := newRoom(code, rr.source, *playlist, rr.clock, random)
+	rr.open(room)
+
+	log.Printf("Room %v created", code)
+
+	return room, nil
+}
+
+// Returns the room matching code, restoring it from its snapshot if needed
+func (rr *RoomRegistry) getOrRestore(code string) (*Room, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	return rr.getOrRestoreLocked(code)
+}
+
+func (rr *RoomRegistry) getOrRestoreLocked(code string) (*Room, error) {
+	if room, ok := rr.rooms[code]; ok {
+		return room, nil
+	}
+
+	notFound := errors.New("Room not found")
+	if rr.store == nil {
+		return nil, notFound
+	}
+
+	snapshot, ok, err := rr.store.Load(code)
+	if err != nil {
+		log.Printf("Can't load room %v. Err: %v", code, err)
+		return nil, notFound
+	}
+	if !ok {
+		return nil, notFound
+	}
+	if rr.clock.Now().Sub(snapshot.SavedAt) > maxSnapshotAge {
+		rr.store.Delete(code)
+		return nil, notFound
+	}
+
+	random := newRandom(rr.clock.Now().UnixNano())
+	room := restoreRoom(snapshot, rr.source, rr.clock, random)
+	rr.open(room)
+
+	log.Printf("Room %v restored, %v rounds played", code, len(snapshot.SongsPlayed))
+
+	return room, nil
+}
+
+// Registers room and starts its game loop
+func (rr *RoomRegistry) open(room *Room) {
+	room.onClose = rr.remove
+	room.store = rr.store
+	room.matchLogDir = rr.matchLogDir
+	room.sessions = rr.sessions
+	rr.rooms[room.Code] = room
+
+	// A restored match is logged from where it resumes
+	if room.Game.Started {
+		room.openMatchLog()
+	}
+	go room.run()
+}
+
+// Sends cmd to the room matching code, creating the room if needed
+func (rr *RoomRegistry) sendOrCreate(code string, cmd command) (*Room, error) {
+	for {
+		room, err := rr.getOrCreate(code)
+		if err != nil {
+			return nil, err
+		}
+
+		// A room closing meanwhile is unregistered first, so the next try creates a new one
+		if room.send(cmd) {
+			return room, nil
+		}
+	}
+}
+
+func (rr *RoomRegistry) get(code string) (*Room, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	room, ok := rr.rooms[code]
+	if !ok {
+		return nil, errors.New("Room not found")
+	}
+
+	return room, nil
+}
+
+func (rr *RoomRegistry) remove(room *Room) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.rooms[room.Code] == room {
+		delete(rr.rooms, room.Code)
+	}
+}
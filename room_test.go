@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the events a room sends, for tests
+type recordingClient struct {
+	id     string
+	mu     sync.Mutex
+	events []recordedEvent
+}
+
+type recordedEvent struct {
+	message string
+	args    []interface{}
+}
+
+func newRecordingClient(id string) *recordingClient {
+	return &recordingClient{id: id}
+}
+
+func (c *recordingClient) Id() string {
+	return c.id
+}
+
+func (c *recordingClient) Emit(message string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, recordedEvent{message: message, args: args})
+	return nil
+}
+
+// Returns the first argument of every message event received
+func (c *recordingClient) received(message string) []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	args := make([]interface{}, 0)
+	for _, event := range c.events {
+		if event.message == message && len(event.args) > 0 {
+			args = append(args, event.args[0])
+		}
+	}
+	return args
+}
+
+// Runs fn on the room loop, and waits for it to be done
+type probeCommand struct {
+	fn   func(r *Room)
+	done chan struct{}
+}
+
+func (c probeCommand) apply(r *Room) {
+	c.fn(r)
+	close(c.done)
+}
+
+func probe(t *testing.T, room *Room, fn func(r *Room)) {
+	done := make(chan struct{})
+	if !room.send(probeCommand{fn: fn, done: done}) {
+		t.Fatal("Room closed")
+	}
+	<-done
+}
+
+// Advances the clock second by second until cond holds. Songs resolve off the room loop, so
+// the wall clock bounds the wait too.
+func advanceUntil(t *testing.T, room *Room, clock *FakeClock, what string, cond func(r *Room) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reached := false
+		probe(t, room, func(r *Room) {
+			reached = cond(r)
+		})
+		if reached {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %v", what)
+		}
+		clock.Advance(time.Second)
+	}
+}
+
+func testSongs(n int) []Song {
+	songs := make([]Song, n)
+	for i := range songs {
+		songs[i] = Song{
+			ID:      fmt.Sprint(i),
+			Preview: fmt.Sprintf("https://example.com/%v.mp3", i),
+			Title:   fmt.Sprintf("Title %v", i),
+			Artist:  Artist{ID: fmt.Sprint(i), Name: fmt.Sprintf("Artist %v", i)},
+			Year:    1970 + i,
+		}
+	}
+	return songs
+}
+
+func TestFullMatch(t *testing.T) {
+	tests := []struct {
+		mode string
+		// Points Alice earns each round by answering first, right at the start
+		roundPoints int
+	}{
+		{freeTextMode, 10 + 10 + 5},
+		{multipleChoiceMode, 2 * (10 + 10 + 5)},
+		{buzzerMode, 10 + 10 + 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			songs := testSongs(12)
+			clock := newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+			room := newRoom("test", newStaticSource(songs), Playlist{Songs: songs, Length: len(songs)}, clock, newRandom(1))
+			go room.run()
+
+			alice, bob := newRecordingClient("alice"), newRecordingClient("bob")
+			room.send(joinCommand{client: alice, playerName: "Alice"})
+			room.send(joinCommand{client: bob, playerName: "Bob"})
+			room.send(configureCommand{client: alice, params: map[string]interface{}{"mode": test.mode, "rounds": 10.0}})
+			room.send(startCommand{client: alice})
+
+			for nb := 1; nb <= 10; nb++ {
+				advanceUntil(t, room, clock, fmt.Sprintf("round %v", nb), func(r *Room) bool {
+					return r.phase == guessPhase && r.Game.CurrentRound.Nb == nb
+				})
+
+				var title string
+				var right, wrong int
+				probe(t, room, func(r *Room) {
+					round := &r.Game.CurrentRound
+					title = round.Song.Title
+					for i := range round.choices {
+						if round.isRightChoice(i) {
+							right = i
+						} else {
+							wrong = i
+						}
+					}
+				})
+
+				switch test.mode {
+				case freeTextMode:
+					room.send(guessCommand{client: bob, guess: "Nothing like it"})
+					room.send(guessCommand{client: alice, guess: title})
+				case multipleChoiceMode:
+					room.send(chooseCommand{client: alice, option: right})
+					room.send(chooseCommand{client: bob, option: wrong})
+				case buzzerMode:
+					room.send(buzzCommand{client: alice})
+					room.send(guessCommand{client: alice, guess: title})
+				}
+
+				advanceUntil(t, room, clock, fmt.Sprintf("round %v reveal", nb), func(r *Room) bool {
+					return r.phase != guessPhase
+				})
+			}
+
+			advanceUntil(t, room, clock, "match end", func(r *Room) bool {
+				return r.phase == lobbyPhase
+			})
+
+			previews := make(map[string]bool)
+			for _, event := range alice.received("songStarted") {
+				previews[event.(SocketIOSongStartedEvent).SongPreviewURI] = true
+			}
+			if len(previews) != 10 {
+				t.Errorf("%v different songs played, expected 10", len(previews))
+			}
+
+			finished := alice.received("gameFinished")
+			if len(finished) != 1 {
+				t.Fatalf("%v 'gameFinished' events, expected 1", len(finished))
+			}
+			scores := make(map[string]int)
+			for _, player := range finished[0].(SocketIOGameFinishedEvent).LeaderBoard {
+				scores[player.Name] = player.Score
+			}
+			if scores["Alice"] != 10*test.roundPoints || scores["Bob"] != 0 {
+				t.Errorf("Scores %v, expected Alice %v and Bob 0", scores, 10*test.roundPoints)
+			}
+
+			room.send(leaveCommand{client: alice})
+			room.send(leaveCommand{client: bob})
+			select {
+			case <-room.done:
+			case <-time.After(5 * time.Second):
+				t.Error("Room still open once everyone left")
+			}
+		})
+	}
+}
+
+// Starts the first round of a room where Alice and Bob play, Alice having configured it with settings
+func newStartedRoom(t *testing.T, settings map[string]interface{}) (*Room, *FakeClock, *recordingClient, *recordingClient) {
+	songs := testSongs(8)
+	clock := newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	room := newRoom("test", newStaticSource(songs), Playlist{Songs: songs, Length: len(songs)}, clock, newRandom(1))
+
+	alice, bob := newRecordingClient("alice"), newRecordingClient("bob")
+	joinCommand{client: alice, playerName: "Alice"}.apply(room)
+	joinCommand{client: bob, playerName: "Bob"}.apply(room)
+	configureCommand{client: alice, params: settings}.apply(room)
+	if refused := alice.received("error"); len(refused) != 0 {
+		t.Fatalf("Can't configure the room: %v", refused)
+	}
+
+	room.Game.Started = true
+	room.startRound(1, songs[0])
+
+	return room, clock, alice, bob
+}
+
+// Starts a multiple choice round with shared team credit, Alice and Bob playing in the same team
+func newTeamChoiceRoom(t *testing.T) (*Room, *recordingClient, *recordingClient) {
+	room, _, alice, bob := newStartedRoom(t, map[string]interface{}{
+		"mode":               multipleChoiceMode,
+		"shared_team_credit": true,
+		"rounds":             1.0,
+	})
+
+	team := newTeam("Blue")
+	for _, player := range room.Game.Players {
+		room.Game.joinTeam(player, team)
+	}
+
+	return room, alice, bob
+}
+
+func TestChooseSharedTeamCredit(t *testing.T) {
+	room, alice, bob := newTeamChoiceRoom(t)
+
+	right := -1
+	for i := range room.Game.CurrentRound.choices {
+		if room.Game.CurrentRound.isRightChoice(i) {
+			right = i
+		}
+	}
+
+	chooseCommand{client: alice, option: right}.apply(room)
+	chooseCommand{client: bob, option: right}.apply(room)
+
+	scores := make(map[string]int)
+	for _, player := range room.Game.Players {
+		scores[player.Name] = player.Score
+	}
+	if scores["Alice"] == 0 || scores["Bob"] != 0 {
+		t.Errorf("Scores %v, expected only Alice to score for the team", scores)
+	}
+	if len(room.Game.CurrentRound.Scores) != 1 {
+		t.Errorf("%v round scores, expected 1", len(room.Game.CurrentRound.Scores))
+	}
+}
+
+func TestNoHintsInMultipleChoice(t *testing.T) {
+	room, alice, _ := newTeamChoiceRoom(t)
+
+	for room.phase == guessPhase {
+		tickCommand{}.apply(room)
+	}
+
+	if hints := alice.received("hint"); len(hints) != 0 {
+		t.Errorf("%v hints given in multiple choice mode", len(hints))
+	}
+}
+
+func TestBuzzPauseKeepsSpeedBonus(t *testing.T) {
+	room, clock, alice, bob := newStartedRoom(t, map[string]interface{}{"mode": buzzerMode, "rounds": 1.0})
+
+	// Bob buzzes right away and lets his answer time run out, the countdown being paused meanwhile
+	buzzCommand{client: bob}.apply(room)
+	for i := 0; i < room.Game.Settings.BuzzTime; i++ {
+		clock.Advance(time.Second)
+		tickCommand{}.apply(room)
+	}
+
+	buzzCommand{client: alice}.apply(room)
+	guessCommand{client: alice, guess: room.Game.CurrentRound.Song.Title}.apply(room)
+
+	settings := room.Game.Settings
+	award := settings.scoreAward(titleField, settings.TitlePoints, 0, 1, 0)
+	if score := room.Game.Players[0].Score; score != award.total() {
+		t.Errorf("Alice scored %v, expected the full speed bonus: %v", score, award.total())
+	}
+}
+
+func TestSongHiddenWhileGuessing(t *testing.T) {
+	room, _, alice, _ := newStartedRoom(t, map[string]interface{}{"rounds": 1.0})
+	song := room.Game.CurrentRound.Song
+
+	carol := newRecordingClient("carol")
+	joinCommand{client: carol, playerName: "Carol"}.apply(room)
+	guessCommand{client: alice, guess: song.Title}.apply(room)
+
+	joined := carol.received("joined")[0].(SocketIOConnectedEvent).Game.CurrentRound
+	if joined.Song != nil || joined.SongPreviewURI != song.Preview || joined.Type != songRound {
+		t.Errorf("Round sent on join while guessing: %+v", joined)
+	}
+	updates := carol.received("update")
+	if len(updates) == 0 {
+		t.Error("No update sent once Alice found the title")
+	}
+	for _, event := range updates {
+		if round := event.(SocketIOUpdateEvent).Game.CurrentRound; round.Song != nil {
+			t.Errorf("Song sent in an update while guessing: %+v", round.Song)
+		}
+	}
+
+	for room.phase == guessPhase {
+		tickCommand{}.apply(room)
+	}
+
+	dave := newRecordingClient("dave")
+	joinCommand{client: dave, playerName: "Dave"}.apply(room)
+	revealed := dave.received("joined")[0].(SocketIOConnectedEvent).Game.CurrentRound
+	if revealed.Song == nil || revealed.Song.Title != song.Title {
+		t.Errorf("Song not sent once revealed: %+v", revealed.Song)
+	}
+}
+
+func TestHostRightsPassOn(t *testing.T) {
+	songs := testSongs(4)
+	clock := newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	room := newRoom("test", newStaticSource(songs), Playlist{Songs: songs, Length: len(songs)}, clock, newRandom(1))
+
+	alice, bob := newRecordingClient("alice"), newRecordingClient("bob")
+	joinCommand{client: alice, playerName: "Alice"}.apply(room)
+	joinCommand{client: bob, playerName: "Bob"}.apply(room)
+
+	configureCommand{client: bob, params: map[string]interface{}{"rounds": 1.0}}.apply(room)
+	if refused := bob.received("error"); len(refused) != 1 {
+		t.Fatalf("Bob configured the game while Alice hosts it")
+	}
+
+	// Alice closes her tab, staying in the game until the room closes
+	disconnectCommand{client: alice}.apply(room)
+	configureCommand{client: bob, params: map[string]interface{}{"rounds": 1.0}}.apply(room)
+	if refused := bob.received("error"); len(refused) != 1 {
+		t.Errorf("Bob can't configure the game once Alice is gone: %v", refused)
+	}
+	if room.Game.Settings.Rounds != 1 {
+		t.Errorf("%v rounds, expected Bob's settings", room.Game.Settings.Rounds)
+	}
+
+	startCommand{client: bob}.apply(room)
+	if !room.Game.Started {
+		t.Error("Bob can't start the game once Alice is gone")
+	}
+}
@@ -2,10 +2,13 @@ package main
 
 import "github.com/gin-gonic/gin"
 
+// Origin of the web client, allowed to call the API from the browser
+const clientOrigin = "http://localhost:8081"
+
 func initRouter() *gin.Engine {
 	router := gin.New()
 
-	router.Use(GinMiddleware("http://localhost:8081"))
+	router.Use(GinMiddleware(clientOrigin))
 
 	return router
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+
+	"github.com/satori/go.uuid"
+)
+
+// Scoring curves give the share of the speed bonus earned, from the round progress (0 at start, 1 at the end)
+var scoringCurves = map[string]func(progress float64) float64{
+	// No bonus at all
+	"flat": func(progress float64) float64 {
+		return 0
+	},
+	"linear": func(progress float64) float64 {
+		return 1 - progress
+	},
+	// Full bonus during the first third of the round, half during the second one
+	"stepped": func(progress float64) float64 {
+		switch {
+		case progress < 1.0/3:
+			return 1
+		case progress < 2.0/3:
+			return 0.5
+		default:
+			return 0
+		}
+	},
+	"exponential": func(progress float64) float64 {
+		return math.Exp(-3 * progress)
+	},
+}
+
+// Points earned by a player for finding a field of the song
+type ScoreAward struct {
+	// artistField or titleField
+	Field string `json:"field"`
+	Base  int    `json:"base"`
+	// Removed from the base points, for the hints given before the field was found
+	HintPenalty int `json:"hint_penalty"`
+	Bonus       int `json:"bonus"`
+	// Position among the players who found the field, starting at 1
+	Rank      int `json:"rank"`
+	RankBonus int `json:"rank_bonus"`
+}
+
+func (a *ScoreAward) total() int {
+	return a.Base - a.HintPenalty + a.Bonus + a.RankBonus
+}
+
+// Points earned by a player during a round
+type RoundScore struct {
+	PlayerID   uuid.UUID    `json:"player_id"`
+	PlayerName string       `json:"player_name"`
+	Total      int          `json:"total"`
+	Awards     []ScoreAward `json:"awards"`
+}
+
+// Computes the points earned for finding field, elapsed seconds of guess time after the round start,
+// as rank-th player and after the given number of hints
+func (s *GameSettings) scoreAward(field string, base, elapsed, rank, hints int) ScoreAward {
+	progress := float64(elapsed) / float64(s.GuessTime)
+	progress = math.Max(0, math.Min(1, progress))
+
+	bonus := float64(s.SpeedBonus) * scoringCurves[s.ScoringCurve](progress)
+
+	rankBonus := 0
+	if rank <= len(s.RankBonuses) {
+		rankBonus = s.RankBonuses[rank-1]
+	}
+
+	return ScoreAward{
+		Field:       field,
+		Base:        base,
+		HintPenalty: s.hintPenalty(base, hints),
+		Bonus:       int(math.Round(bonus)),
+		Rank:        rank,
+		RankBonus:   rankBonus,
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestScoreAward(t *testing.T) {
+	tests := []struct {
+		name    string
+		curve   string
+		elapsed int
+		rank    int
+		hints   int
+		want    ScoreAward
+	}{
+		{"Instant answer", "linear", 0, 1, 0, ScoreAward{Base: 10, Bonus: 10, Rank: 1, RankBonus: 5}},
+		{"Half time", "linear", 15, 2, 0, ScoreAward{Base: 10, Bonus: 5, Rank: 2, RankBonus: 3}},
+		{"Last second", "linear", 30, 4, 0, ScoreAward{Base: 10, Bonus: 0, Rank: 4}},
+		{"Past the guess time", "linear", 45, 1, 0, ScoreAward{Base: 10, Bonus: 0, Rank: 1, RankBonus: 5}},
+		{"Flat", "flat", 0, 1, 0, ScoreAward{Base: 10, Rank: 1, RankBonus: 5}},
+		{"Stepped, second third", "stepped", 12, 1, 0, ScoreAward{Base: 10, Bonus: 5, Rank: 1, RankBonus: 5}},
+		{"Exponential", "exponential", 10, 1, 0, ScoreAward{Base: 10, Bonus: 4, Rank: 1, RankBonus: 5}},
+		{"After a hint", "linear", 0, 1, 1, ScoreAward{Base: 10, HintPenalty: 2, Bonus: 10, Rank: 1, RankBonus: 5}},
+	}
+
+	for _, test := range tests {
+		settings := defaultGameSettings()
+		settings.ScoringCurve = test.curve
+
+		test.want.Field = titleField
+		if got := settings.scoreAward(titleField, 10, test.elapsed, test.rank, test.hints); got != test.want {
+			t.Errorf("%v: got %+v, expected %+v", test.name, got, test.want)
+		}
+	}
+}
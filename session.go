@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// What a session token vouches for: the player, in the room instance it joined
+type SessionClaims struct {
+	RoomCode string `json:"room"`
+	// Changes whenever a room is created again under the same code, expiring older tokens
+	RoomSession string `json:"session"`
+	PlayerID    string `json:"player"`
+}
+
+// SessionSigner issues the tokens players get back in their room with, signed with HMAC-SHA256
+type SessionSigner struct {
+	secret []byte
+}
+
+func newSessionSigner(secret []byte) *SessionSigner {
+	return &SessionSigner{secret: secret}
+}
+
+// Signs with a random secret, so tokens don't survive a restart
+func newRandomSessionSigner() *SessionSigner {
+	return newSessionSigner([]byte(newSessionID()))
+}
+
+// Returns a random id, for secrets and room sessions
+func newSessionID() string {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+func (s *SessionSigner) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Returns a token made of the claims and their signature
+func (s *SessionSigner) issue(claims SessionClaims) string {
+	data, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + s.sign(payload)
+}
+
+func (s *SessionSigner) verify(token string) (SessionClaims, error) {
+	var claims SessionClaims
+	invalid := errors.New("Invalid session token")
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return claims, invalid
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return claims, invalid
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, invalid
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return claims, invalid
+	}
+
+	return claims, nil
+}
+
+// Returns the token player gets back in the room with. Empty when the room issues no token,
+// as replayed rooms.
+func (r *Room) issueToken(player *Player) string {
+	if r.sessions == nil {
+		return ""
+	}
+
+	return r.sessions.issue(SessionClaims{RoomCode: r.Code, RoomSession: r.sessionID, PlayerID: player.ID.String()})
+}
+
+// Returns the player claims are about, if they were issued by this instance of the room
+func (r *Room) claimedPlayer(claims SessionClaims) (*Player, error) {
+	if claims.RoomCode != r.Code || claims.RoomSession != r.sessionID {
+		return nil, errors.New("Session expired")
+	}
+
+	return r.Game.getPlayerByID(claims.PlayerID)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionTokens(t *testing.T) {
+	signer := newSessionSigner([]byte("secret"))
+	clock := newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	room := newRoom("ab", newStaticSource(nil), Playlist{}, clock, newRandom(1))
+	room.sessions = signer
+
+	alice, bob := newPlayer("Alice"), newPlayer("Bob")
+	room.Game.join(alice)
+	room.Game.join(bob)
+
+	token := room.issueToken(alice)
+	parts := strings.Split(token, ".")
+	payload, signature := parts[0], parts[1]
+
+	// Alice's claims, rewritten as Bob's
+	data, _ := base64.RawURLEncoding.DecodeString(payload)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(data), alice.ID.String(), bob.ID.String(), 1)))
+
+	otherRoom := newRoom("cd", newStaticSource(nil), Playlist{}, clock, newRandom(1))
+	otherRoom.sessions = signer
+	otherRoom.Game.join(alice)
+
+	// The same room, created again once closed
+	earlierRoom := newRoom("ab", newStaticSource(nil), Playlist{}, clock, newRandom(1))
+	earlierRoom.sessions = signer
+	earlierRoom.Game.join(alice)
+
+	tests := []struct {
+		name  string
+		token string
+		// Whether the token is valid for room, as Alice
+		valid bool
+	}{
+		{"Issued by the room", token, true},
+		{"Tampered payload", forged + "." + signature, false},
+		{"Tampered signature", payload + "." + signer.sign(payload+"x"), false},
+		{"Signed with another secret", newSessionSigner([]byte("other")).issue(SessionClaims{RoomCode: "ab", RoomSession: room.sessionID, PlayerID: alice.ID.String()}), false},
+		{"Without signature", payload, false},
+		{"Extra part", token + ".AA", false},
+		{"Empty", "", false},
+		{"Issued by another room", otherRoom.issueToken(alice), false},
+		{"Issued by an earlier instance of the room", earlierRoom.issueToken(alice), false},
+	}
+
+	for _, test := range tests {
+		player, err := func() (*Player, error) {
+			claims, err := signer.verify(test.token)
+			if err != nil {
+				return nil, err
+			}
+			return room.claimedPlayer(claims)
+		}()
+
+		if test.valid && (err != nil || player != alice) {
+			t.Errorf("%v: got %v, %v, expected Alice", test.name, player, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v: accepted as %v", test.name, player.Name)
+		}
+	}
+}
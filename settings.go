@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type GameSettings struct {
+	// freeTextMode, multipleChoiceMode or buzzerMode
+	Mode   string `json:"mode"`
+	Rounds int    `json:"rounds"`
+	// Time given to players to guess, in seconds
+	GuessTime int `json:"guess_time"`
+	// Pause between the answer reveal and the next round, in seconds
+	RevealPause  int `json:"reveal_pause"`
+	ArtistPoints int `json:"artist_points"`
+	TitlePoints  int `json:"title_points"`
+	// Extra points for an instant answer, decreasing with time along the scoring curve
+	SpeedBonus   int    `json:"speed_bonus"`
+	ScoringCurve string `json:"scoring_curve"`
+	// Extra points for the first players to find a field, by rank
+	RankBonuses []int `json:"rank_bonuses"`
+	// How songs are drawn, one of songPickers
+	SongPicker string `json:"song_picker"`
+	// Number of previous matches whose songs shouldn't be played again
+	AvoidRecentMatches int `json:"avoid_recent_matches"`
+	// When hints are given, in percent of the guess time
+	HintMarks []int `json:"hint_marks"`
+	// Percentage of the points removed for each hint given
+	HintPenalty int `json:"hint_penalty"`
+	// A field found by a team member counts for the whole team, who can't score it again
+	SharedTeamCredit bool `json:"shared_team_credit"`
+	// Types of the rounds, repeated in order across the match
+	RoundTypes []string `json:"round_types"`
+	// How guesses are matched against answers, one of answerMatchers
+	Matching string `json:"matching"`
+	// Similarity to an answer, in percent, from which a wrong guess is told to be close. 0 disables it
+	CloseThreshold int `json:"close_threshold"`
+	// Points for the exact release year, less being given the further a guess is
+	YearPoints int `json:"year_points"`
+	// Time given to answer after buzzing, in seconds
+	BuzzTime int `json:"buzz_time"`
+	// Seconds of countdown a wrong buzzer has to wait before buzzing again
+	BuzzLockout int `json:"buzz_lockout"`
+}
+
+func defaultGameSettings() GameSettings {
+	return GameSettings{
+		Mode:           freeTextMode,
+		Rounds:         10,
+		GuessTime:      30,
+		RevealPause:    10,
+		ArtistPoints:   10,
+		TitlePoints:    10,
+		SpeedBonus:     10,
+		ScoringCurve:   "linear",
+		RankBonuses:    []int{5, 3, 1},
+		SongPicker:     "shuffle",
+		HintMarks:      []int{33, 66},
+		HintPenalty:    20,
+		RoundTypes:     []string{songRound},
+		Matching:       "numbers",
+		CloseThreshold: 60,
+		YearPoints:     20,
+		BuzzTime:       5,
+		BuzzLockout:    10,
+	}
+}
+
+// Returns a copy of the settings with the given fields overridden, validated
+func (s GameSettings) apply(params map[string]interface{}) (GameSettings, error) {
+	for key, value := range params {
+		var err error
+
+		switch key {
+		case "mode":
+			s.Mode, err = stringSetting(key, value)
+		case "rounds":
+			s.Rounds, err = intSetting(key, value)
+		case "guess_time":
+			s.GuessTime, err = intSetting(key, value)
+		case "reveal_pause":
+			s.RevealPause, err = intSetting(key, value)
+		case "artist_points":
+			s.ArtistPoints, err = intSetting(key, value)
+		case "title_points":
+			s.TitlePoints, err = intSetting(key, value)
+		case "speed_bonus":
+			s.SpeedBonus, err = intSetting(key, value)
+		case "scoring_curve":
+			s.ScoringCurve, err = stringSetting(key, value)
+		case "rank_bonuses":
+			s.RankBonuses, err = intListSetting(key, value)
+		case "song_picker":
+			s.SongPicker, err = stringSetting(key, value)
+		case "avoid_recent_matches":
+			s.AvoidRecentMatches, err = intSetting(key, value)
+		case "hint_marks":
+			s.HintMarks, err = intListSetting(key, value)
+		case "hint_penalty":
+			s.HintPenalty, err = intSetting(key, value)
+		case "shared_team_credit":
+			s.SharedTeamCredit, err = boolSetting(key, value)
+		case "round_types":
+			s.RoundTypes, err = stringListSetting(key, value)
+		case "matching":
+			s.Matching, err = stringSetting(key, value)
+		case "close_threshold":
+			s.CloseThreshold, err = intSetting(key, value)
+		case "year_points":
+			s.YearPoints, err = intSetting(key, value)
+		case "buzz_time":
+			s.BuzzTime, err = intSetting(key, value)
+		case "buzz_lockout":
+			s.BuzzLockout, err = intSetting(key, value)
+		default:
+			err = fmt.Errorf("Unknown setting '%v'", key)
+		}
+
+		if err != nil {
+			return s, err
+		}
+	}
+
+	return s, s.validate()
+}
+
+// JSON numbers are decoded as float64
+func intSetting(key string, value interface{}) (int, error) {
+	number, ok := value.(float64)
+	if !ok || number != math.Trunc(number) {
+		return 0, fmt.Errorf("'%v' must be an integer", key)
+	}
+	return int(number), nil
+}
+
+func intListSetting(key string, value interface{}) ([]int, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%v' must be a list of integers", key)
+	}
+
+	list := make([]int, len(values))
+	for i, v := range values {
+		number, err := intSetting(key, v)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' must be a list of integers", key)
+		}
+		list[i] = number
+	}
+
+	return list, nil
+}
+
+func boolSetting(key string, value interface{}) (bool, error) {
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("'%v' must be a boolean", key)
+	}
+	return b, nil
+}
+
+func stringListSetting(key string, value interface{}) ([]string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%v' must be a list of strings", key)
+	}
+
+	list := make([]string, len(values))
+	for i, v := range values {
+		str, err := stringSetting(key, v)
+		if err != nil {
+			return nil, fmt.Errorf("'%v' must be a list of strings", key)
+		}
+		list[i] = str
+	}
+
+	return list, nil
+}
+
+func stringSetting(key string, value interface{}) (string, error) {
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("'%v' must be a string", key)
+	}
+	return str, nil
+}
+
+func (s *GameSettings) validate() error {
+	switch s.Mode {
+	case freeTextMode, multipleChoiceMode, buzzerMode:
+	default:
+		return fmt.Errorf("Unknown 'mode' '%v'", s.Mode)
+	}
+	if s.Rounds < 1 || s.Rounds > 50 {
+		return fmt.Errorf("'rounds' must be between 1 and 50, got %v", s.Rounds)
+	}
+	// Deezer previews only last 30 seconds
+	if s.GuessTime < 5 || s.GuessTime > 30 {
+		return fmt.Errorf("'guess_time' must be between 5 and 30, got %v", s.GuessTime)
+	}
+	if s.RevealPause < 0 || s.RevealPause > 60 {
+		return fmt.Errorf("'reveal_pause' must be between 0 and 60, got %v", s.RevealPause)
+	}
+	if s.ArtistPoints < 0 || s.ArtistPoints > 100 {
+		return fmt.Errorf("'artist_points' must be between 0 and 100, got %v", s.ArtistPoints)
+	}
+	if s.TitlePoints < 0 || s.TitlePoints > 100 {
+		return fmt.Errorf("'title_points' must be between 0 and 100, got %v", s.TitlePoints)
+	}
+	if s.SpeedBonus < 0 || s.SpeedBonus > 100 {
+		return fmt.Errorf("'speed_bonus' must be between 0 and 100, got %v", s.SpeedBonus)
+	}
+	if len(s.RankBonuses) > 10 {
+		return fmt.Errorf("'rank_bonuses' can't have more than 10 ranks, got %v", len(s.RankBonuses))
+	}
+	for _, bonus := range s.RankBonuses {
+		if bonus < 0 || bonus > 100 {
+			return fmt.Errorf("'rank_bonuses' must be between 0 and 100, got %v", bonus)
+		}
+	}
+	if _, ok := scoringCurves[s.ScoringCurve]; !ok {
+		return fmt.Errorf("Unknown 'scoring_curve' '%v'", s.ScoringCurve)
+	}
+	if len(s.HintMarks) > 6 {
+		return fmt.Errorf("'hint_marks' can't have more than 6 hints, got %v", len(s.HintMarks))
+	}
+	for i, mark := range s.HintMarks {
+		if mark < 1 || mark > 99 {
+			return fmt.Errorf("'hint_marks' must be between 1 and 99, got %v", mark)
+		}
+		if i > 0 && mark <= s.HintMarks[i-1] {
+			return fmt.Errorf("'hint_marks' must be in increasing order")
+		}
+	}
+	if s.HintPenalty < 0 || s.HintPenalty > 100 {
+		return fmt.Errorf("'hint_penalty' must be between 0 and 100, got %v", s.HintPenalty)
+	}
+	if _, ok := songPickers[s.SongPicker]; !ok {
+		return fmt.Errorf("Unknown 'song_picker' '%v'", s.SongPicker)
+	}
+	if s.AvoidRecentMatches < 0 || s.AvoidRecentMatches > maxRecentMatches {
+		return fmt.Errorf("'avoid_recent_matches' must be between 0 and %v, got %v", maxRecentMatches, s.AvoidRecentMatches)
+	}
+	if len(s.RoundTypes) < 1 || len(s.RoundTypes) > 10 {
+		return fmt.Errorf("'round_types' must have between 1 and 10 types, got %v", len(s.RoundTypes))
+	}
+	for _, roundType := range s.RoundTypes {
+		if _, ok := guessEvaluators[roundType]; !ok {
+			return fmt.Errorf("Unknown 'round_types' '%v'", roundType)
+		}
+		// Choices are songs
+		if roundType != songRound && s.Mode == multipleChoiceMode {
+			return fmt.Errorf("'round_types' can only be '%v' in multiple choice mode", songRound)
+		}
+	}
+	if _, ok := answerMatchers[s.Matching]; !ok {
+		return fmt.Errorf("Unknown 'matching' '%v'", s.Matching)
+	}
+	// Guesses above the match threshold aren't wrong
+	if s.CloseThreshold < 0 || s.CloseThreshold >= matchThreshold*100 {
+		return fmt.Errorf("'close_threshold' must be between 0 and %v, got %v", matchThreshold*100-1, s.CloseThreshold)
+	}
+	if s.YearPoints < 0 || s.YearPoints > 100 {
+		return fmt.Errorf("'year_points' must be between 0 and 100, got %v", s.YearPoints)
+	}
+	if s.BuzzTime < 1 || s.BuzzTime > 30 {
+		return fmt.Errorf("'buzz_time' must be between 1 and 30, got %v", s.BuzzTime)
+	}
+	if s.BuzzLockout < 0 || s.BuzzLockout > 30 {
+		return fmt.Errorf("'buzz_lockout' must be between 0 and 30, got %v", s.BuzzLockout)
+	}
+
+	return nil
+}
+
+// Type of the round nb, starting at 1
+func (s *GameSettings) roundType(nb int) string {
+	return s.RoundTypes[(nb-1)%len(s.RoundTypes)]
+}
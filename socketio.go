@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/mlsquires/socketio"
+)
+
+// Events queued for a Socket.IO client before it is considered too slow and dropped
+const socketIOSendBuffer = 64
+
+type socketIOEvent struct {
+	message string
+	args    []interface{}
+}
+
+// SocketIOClient sends room events to a Socket.IO socket. Emitting writes to the connection
+// without deadline, so events are queued as for WebSocket clients, a slow socket never holding
+// the room loop.
+type SocketIOClient struct {
+	socket socketio.Socket
+	send   chan socketIOEvent
+	// Closed once the socket disconnected or was dropped, to stop queuing events
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSocketIOClient(socket socketio.Socket) *SocketIOClient {
+	return &SocketIOClient{
+		socket: socket,
+		send:   make(chan socketIOEvent, socketIOSendBuffer),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *SocketIOClient) Id() string {
+	return c.socket.Id()
+}
+
+func (c *SocketIOClient) Emit(message string, args ...interface{}) error {
+	select {
+	case <-c.done:
+		return errors.New("Connection closed")
+	default:
+	}
+
+	select {
+	case c.send <- socketIOEvent{message: message, args: args}:
+		return nil
+	default:
+		log.Printf("Client %v too slow, closing", c.Id())
+		c.close()
+		// Closes the connection once the pending write is through, the socket then disconnecting
+		go c.socket.Emit("disconnect")
+		return errors.New("Connection too slow")
+	}
+}
+
+func (c *SocketIOClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Emits queued events until the socket disconnects
+func (c *SocketIOClient) writeLoop() {
+	for {
+		select {
+		case event := <-c.send:
+			if err := c.socket.Emit(event.message, event.args...); err != nil {
+				log.Printf("Can't send %v to client %v. Err: %v", event.message, c.Id(), err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
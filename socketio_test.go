@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mlsquires/socketio"
+)
+
+// A socket whose connection stalls, every emit blocking until unblock is closed
+type stalledSocket struct {
+	socketio.Socket
+	unblock      chan struct{}
+	disconnected chan struct{}
+}
+
+func (s *stalledSocket) Id() string {
+	return "stalled"
+}
+
+func (s *stalledSocket) Emit(message string, args ...interface{}) error {
+	<-s.unblock
+	if message == "disconnect" {
+		close(s.disconnected)
+	}
+	return nil
+}
+
+func TestSocketIOClientStalled(t *testing.T) {
+	socket := &stalledSocket{unblock: make(chan struct{}), disconnected: make(chan struct{})}
+	client := newSocketIOClient(socket)
+	go client.writeLoop()
+
+	emitted := make(chan error)
+	go func() {
+		for i := 0; i <= socketIOSendBuffer+1; i++ {
+			if err := client.Emit("update", i); err != nil {
+				emitted <- err
+				return
+			}
+		}
+		emitted <- nil
+	}()
+
+	select {
+	case err := <-emitted:
+		if err == nil {
+			t.Fatal("Stalled client not dropped once its queue is full")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Emitting to a stalled client blocks")
+	}
+
+	if err := client.Emit("update", 0); err == nil {
+		t.Error("Events still queued once the client was dropped")
+	}
+
+	// The connection closes once the stalled write is through
+	close(socket.unblock)
+	select {
+	case <-socket.disconnected:
+	case <-time.After(5 * time.Second):
+		t.Error("Dropped client not disconnected")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+)
+
+// SongSource is a music catalog the game can draw songs from
+type SongSource interface {
+	// Lists every track of the catalog
+	ListTracks() ([]Song, error)
+	// Completes the track right before it is played: playable preview URL,
+	// and details the listing doesn't carry
+	Resolve(song Song) (Song, error)
+	// Fetches the picture of the artist
+	ArtistPicture(artist Artist) (string, error)
+}
+
+// Loads every playable track of source into a playlist
+func newPlaylist(source SongSource) (*Playlist, error) {
+	songs, err := source.ListTracks()
+	if err != nil {
+		return nil, err
+	}
+
+	songs = *filterSongsWithoutPreview(&songs)
+	if len(songs) == 0 {
+		return nil, errors.New("Playlist has no playable song")
+	}
+
+	return &Playlist{Songs: songs, Length: len(songs)}, nil
+}
+
+// StaticSource serves a fixed list of songs, whose preview URLs are known upfront
+type StaticSource struct {
+	Songs []Song
+}
+
+func newStaticSource(songs []Song) *StaticSource {
+	return &StaticSource{Songs: songs}
+}
+
+func (s *StaticSource) ListTracks() ([]Song, error) {
+	songs := make([]Song, len(s.Songs))
+	copy(songs, s.Songs)
+
+	return songs, nil
+}
+
+func (s *StaticSource) Resolve(song Song) (Song, error) {
+	if song.Preview == "" {
+		return song, errors.New("Song has no preview")
+	}
+
+	return song, nil
+}
+
+func (s *StaticSource) ArtistPicture(artist Artist) (string, error) {
+	return artist.Picture, nil
+}
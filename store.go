@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshots older than this are dropped instead of restored
+const maxSnapshotAge = 24 * time.Hour
+
+// RoomStore persists room snapshots, so that a restarted server can resume their match
+type RoomStore interface {
+	Save(snapshot RoomSnapshot) error
+	// Returns false if no snapshot of the room was saved
+	Load(code string) (RoomSnapshot, bool, error)
+	Delete(code string) error
+}
+
+// State of a room between two rounds
+type RoomSnapshot struct {
+	Code     string   `json:"code"`
+	Playlist Playlist `json:"playlist"`
+	// The playlist was imported by the host, instead of drawn from the server source
+	Imported bool         `json:"imported"`
+	Players  []*Player    `json:"players"`
+	Teams    []*Team      `json:"teams"`
+	Settings GameSettings `json:"settings"`
+	Started  bool         `json:"started"`
+	// Songs of the rounds played so far in the match
+	SongsPlayed []Song `json:"songs_played"`
+	// Scores of the rounds played so far in the match
+	Rounds        []RoundResult `json:"rounds"`
+	RecentMatches [][]string    `json:"recent_matches"`
+	// Keeps the session tokens of the players valid once restored
+	SessionID string    `json:"session_id"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// Saves the room state. Scores are only consistent between rounds, so nothing is saved while
+// players guess, the snapshot taken when the round ends catching up.
+func (r *Room) snapshot() {
+	if r.store == nil || r.phase == guessPhase || r.phase == resumePhase {
+		return
+	}
+
+	snapshot := RoomSnapshot{
+		Code:          r.Code,
+		Playlist:      r.Playlist,
+		Imported:      r.imported,
+		Players:       r.Game.Players,
+		Teams:         r.Game.Teams,
+		Settings:      r.Game.Settings,
+		Started:       r.Game.Started,
+		SongsPlayed:   r.Game.SongsPlayed,
+		Rounds:        r.Game.RoundsPlayed,
+		RecentMatches: r.Game.recentMatches,
+		SessionID:     r.sessionID,
+		SavedAt:       r.Game.clock.Now(),
+	}
+
+	if err := r.store.Save(snapshot); err != nil {
+		log.Printf("[%v] Can't save room. Err: %v", r.Code, err)
+	}
+}
+
+// Rebuilds a room from its snapshot. A match in progress resumes at the next round,
+// once a player reconnected.
+func restoreRoom(snapshot RoomSnapshot, source SongSource, clock Clock, random Random) *Room {
+	if snapshot.Imported {
+		source = newStaticSource(snapshot.Playlist.Songs)
+	}
+
+	room := newRoom(snapshot.Code, source, snapshot.Playlist, clock, random)
+	room.imported = snapshot.Imported
+	if snapshot.SessionID != "" {
+		room.sessionID = snapshot.SessionID
+	}
+
+	game := &room.Game
+	game.Players = snapshot.Players
+	game.Teams = snapshot.Teams
+	game.Settings = snapshot.Settings
+	game.Started = snapshot.Started
+	game.SongsPlayed = snapshot.SongsPlayed
+	game.RoundsPlayed = snapshot.Rounds
+	game.recentMatches = snapshot.RecentMatches
+	game.CurrentRound = Round{Nb: len(snapshot.SongsPlayed)}
+
+	if game.Started {
+		room.picker = songPickers[game.Settings.SongPicker]()
+		room.picker.reset(game.unplayedSongs(game.selectableSongs(snapshot.Playlist.Songs)))
+		room.phase = resumePhase
+	}
+
+	return room
+}
+
+// Returns the songs not played yet during the match, or all of them if every song was
+func (g *Game) unplayedSongs(songs []Song) []Song {
+	played := make(map[string]bool)
+	for _, song := range g.SongsPlayed {
+		played[song.ID] = true
+	}
+
+	unplayed := make([]Song, 0)
+	for _, song := range songs {
+		if !played[song.ID] {
+			unplayed = append(unplayed, song)
+		}
+	}
+
+	if len(unplayed) == 0 {
+		return songs
+	}
+
+	return unplayed
+}
+
+// FileStore saves every room snapshot as a JSON file of its directory
+type FileStore struct {
+	Dir string
+}
+
+func newFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{Dir: dir}, nil
+}
+
+// Room codes are chosen by players, so they are escaped to stay in the directory
+func (f *FileStore) path(code string) string {
+	return filepath.Join(f.Dir, url.PathEscape(code)+".json")
+}
+
+func (f *FileStore) Save(snapshot RoomSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Write then rename, so that a crash never leaves half a snapshot
+	temp := f.path(snapshot.Code) + ".tmp"
+	if err := ioutil.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, f.path(snapshot.Code))
+}
+
+func (f *FileStore) Load(code string) (RoomSnapshot, bool, error) {
+	var snapshot RoomSnapshot
+
+	data, err := ioutil.ReadFile(f.path(code))
+	if os.IsNotExist(err) {
+		return snapshot, false, nil
+	}
+	if err != nil {
+		return snapshot, false, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, false, err
+	}
+
+	return snapshot, true, nil
+}
+
+func (f *FileStore) Delete(code string) error {
+	err := os.Remove(f.path(code))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
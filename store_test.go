@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSnapshot(rounds int) RoomSnapshot {
+	player := newPlayer("Alice")
+	player.Score = 25 * rounds
+
+	snapshot := RoomSnapshot{
+		Code:     "ab/cd",
+		Players:  []*Player{player},
+		Teams:    []*Team{},
+		Settings: defaultGameSettings(),
+		Started:  true,
+		Rounds:   make([]RoundResult, 0),
+		SavedAt:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for nb := 1; nb <= rounds; nb++ {
+		score := &RoundScore{PlayerID: player.ID, PlayerName: player.Name, Total: 25, Awards: []ScoreAward{{Field: titleField, Base: 10, Bonus: 10, Rank: 1, RankBonus: 5}}}
+		snapshot.Rounds = append(snapshot.Rounds, RoundResult{Nb: nb, Type: songRound, SongID: string(rune('a' + nb)), Scores: []*RoundScore{score}})
+	}
+	return snapshot
+}
+
+func TestRoomStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileStore, err := newFileStore(filepath.Join(dir, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltStore, err := newBoltStore(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boltStore.Close()
+
+	stores := map[string]RoomStore{"file": fileStore, "bolt": boltStore}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, ok, err := store.Load("ab/cd"); ok || err != nil {
+				t.Fatalf("Loaded a room never saved: %v, %v", ok, err)
+			}
+
+			// Saved after each round of a match, then after the first round of the next one
+			for _, rounds := range []int{1, 2, 3, 1} {
+				snapshot := testSnapshot(rounds)
+				if err := store.Save(snapshot); err != nil {
+					t.Fatalf("Can't save: %v", err)
+				}
+
+				loaded, ok, err := store.Load("ab/cd")
+				if !ok || err != nil {
+					t.Fatalf("Can't load: %v, %v", ok, err)
+				}
+				if !reflect.DeepEqual(loaded, snapshot) {
+					t.Errorf("Loaded %+v, expected %+v", loaded, snapshot)
+				}
+			}
+
+			if err := store.Delete("ab/cd"); err != nil {
+				t.Fatalf("Can't delete: %v", err)
+			}
+			if _, ok, _ := store.Load("ab/cd"); ok {
+				t.Error("Room still saved once deleted")
+			}
+			if err := store.Delete("ab/cd"); err != nil {
+				t.Errorf("Deleting a room twice failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRestoreRoomHistory(t *testing.T) {
+	songs := testSongs(6)
+	snapshot := testSnapshot(3)
+	snapshot.Playlist = Playlist{Songs: songs, Length: len(songs)}
+	snapshot.SongsPlayed = songs[:3]
+
+	clock := newFakeClock(snapshot.SavedAt)
+	room := restoreRoom(snapshot, newStaticSource(songs), clock, newRandom(1))
+
+	if room.Game.CurrentRound.Nb != 3 || room.phase != resumePhase {
+		t.Errorf("Restored at round %v, phase %v", room.Game.CurrentRound.Nb, room.phase)
+	}
+	if !reflect.DeepEqual(room.Game.RoundsPlayed, snapshot.Rounds) {
+		t.Errorf("Round history %+v, expected %+v", room.Game.RoundsPlayed, snapshot.Rounds)
+	}
+	if room.Game.Players[0].Score != 75 {
+		t.Errorf("Score %v, expected 75", room.Game.Players[0].Score)
+	}
+}
+
+func TestClosedRoomSnapshotDeleted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	songs := testSongs(4)
+	clock := newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	registry := newRoomRegistry(newStaticSource(songs), store, "", newSessionSigner([]byte("secret")), clock)
+
+	room, err := registry.getOrCreate("ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := newRecordingClient("alice")
+	room.send(joinCommand{client: alice, playerName: "Alice"})
+	room.send(disconnectCommand{client: alice})
+	token := alice.received("joined")[0].(SocketIOConnectedEvent).Token
+
+	if _, ok, _ := store.Load("ab"); !ok {
+		t.Fatal("Room not saved while Alice may come back")
+	}
+
+	// Nobody reconnects in time
+	for !room.closed() {
+		clock.Advance(time.Second)
+		done := make(chan struct{})
+		if room.send(probeCommand{fn: func(r *Room) {}, done: done}) {
+			<-done
+		}
+	}
+
+	if _, ok, _ := store.Load("ab"); ok {
+		t.Error("Room still saved once closed")
+	}
+
+	// The room created again under the same code doesn't take Alice's token
+	claims, err := registry.sessions.verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err = registry.getOrCreate("ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe(t, room, func(r *Room) {
+		if player, err := r.claimedPlayer(claims); err == nil {
+			t.Errorf("%v got back in with a token of the closed room", player.Name)
+		}
+		if len(r.Game.Players) != 0 {
+			t.Errorf("%v players in the new room, expected none", len(r.Game.Players))
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Metadata read from an audio file tags
+type audioTags struct {
+	Title  string
+	Artist string
+	Album  string
+	Year   int
+	Genre  string
+}
+
+// Joins the values of multi-valued tags. Spaced, so that artistAnswers splits it but not a slash
+// within a name, like 'AC/DC'.
+const tagValueSeparator = " / "
+
+var yearRegexp = regexp.MustCompile(`\d{4}`)
+
+func parseYear(s string) int {
+	year, err := strconv.Atoi(yearRegexp.FindString(s))
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// Reads the ID3v2 tag at the start of an MP3 file (v2.2, v2.3 and v2.4)
+func readID3v2Tags(r io.Reader) (audioTags, error) {
+	var tags audioTags
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return tags, err
+	}
+	if string(header[:3]) != "ID3" {
+		return tags, errors.New("No ID3v2 tag")
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := syncsafeInt(header[6:10])
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return tags, err
+	}
+
+	// Whole tag unsynchronisation, only used before v2.4
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsynchronisation(body)
+	}
+
+	// Skip extended header
+	if flags&0x40 != 0 && version >= 3 && len(body) >= 4 {
+		extendedSize := int(binary.BigEndian.Uint32(body[:4]))
+		if version == 4 {
+			extendedSize = syncsafeInt(body[:4])
+		} else {
+			// v2.3 size doesn't include the size field itself
+			extendedSize += 4
+		}
+		if extendedSize > len(body) {
+			return tags, errors.New("Invalid ID3v2 extended header")
+		}
+		body = body[extendedSize:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for len(body) >= headerSize {
+		id := string(body[:idSize])
+		// Reached padding
+		if id[0] == 0 {
+			break
+		}
+
+		var frameSize int
+		var frameFlags byte
+		switch version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			frameFlags = body[9]
+		default:
+			frameSize = syncsafeInt(body[4:8])
+			frameFlags = body[9]
+		}
+
+		if frameSize < 0 || headerSize+frameSize > len(body) {
+			break
+		}
+		frame := body[headerSize : headerSize+frameSize]
+		body = body[headerSize+frameSize:]
+
+		// Frame level unsynchronisation (v2.4)
+		if version == 4 && frameFlags&0x02 != 0 {
+			frame = removeUnsynchronisation(frame)
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = decodeID3Text(frame)
+		case "TPE1", "TP1":
+			tags.Artist = decodeID3Text(frame)
+		case "TALB", "TAL":
+			tags.Album = decodeID3Text(frame)
+		case "TCON", "TCO":
+			tags.Genre = decodeID3Text(frame)
+		case "TYER", "TYE", "TDRC", "TDOR":
+			if tags.Year == 0 {
+				tags.Year = parseYear(decodeID3Text(frame))
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+func syncsafeInt(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func removeUnsynchronisation(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// Decodes a text frame, whose first byte is the encoding
+func decodeID3Text(frame []byte) string {
+	if len(frame) < 1 {
+		return ""
+	}
+
+	var text string
+	data := frame[1:]
+
+	switch frame[0] {
+	// ISO-8859-1
+	case 0:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	// UTF-16 with BOM
+	case 1:
+		bigEndian := true
+		if len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe {
+			bigEndian = false
+		}
+		if len(data) >= 2 && (data[0] == 0xff || data[0] == 0xfe) {
+			data = data[2:]
+		}
+		text = decodeUTF16(data, bigEndian)
+	// UTF-16BE without BOM
+	case 2:
+		text = decodeUTF16(data, true)
+	// UTF-8
+	default:
+		text = string(data)
+	}
+
+	// Multiple values are NUL separated
+	text = strings.Trim(text, "\x00")
+	return strings.TrimSpace(strings.ReplaceAll(text, "\x00", tagValueSeparator))
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// Reads the VORBIS_COMMENT metadata block of a FLAC file
+func readFLACTags(r io.Reader) (audioTags, error) {
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(r, marker); err != nil {
+		return audioTags{}, err
+	}
+	if string(marker) != "fLaC" {
+		return audioTags{}, errors.New("Not a FLAC file")
+	}
+
+	blockHeader := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, blockHeader); err != nil {
+			return audioTags{}, err
+		}
+
+		last := blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7f
+		size := int(blockHeader[1])<<16 | int(blockHeader[2])<<8 | int(blockHeader[3])
+
+		block := make([]byte, size)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return audioTags{}, err
+		}
+
+		if blockType == 4 {
+			return parseVorbisComment(block)
+		}
+		if last {
+			return audioTags{}, errors.New("No Vorbis comment in FLAC file")
+		}
+	}
+}
+
+// Reads the comment header of an Ogg Vorbis or Ogg Opus file
+func readOggTags(r io.Reader) (audioTags, error) {
+	packets, err := readOggPackets(r, 2)
+	if err != nil {
+		return audioTags{}, err
+	}
+
+	comment := packets[1]
+	switch {
+	case bytes.HasPrefix(comment, []byte("\x03vorbis")):
+		return parseVorbisComment(comment[7:])
+	case bytes.HasPrefix(comment, []byte("OpusTags")):
+		return parseVorbisComment(comment[8:])
+	}
+
+	return audioTags{}, errors.New("Unknown Ogg codec")
+}
+
+// Reassembles the first n packets of an Ogg stream
+func readOggPackets(r io.Reader, n int) ([][]byte, error) {
+	packets := make([][]byte, 0, n)
+	var current []byte
+
+	header := make([]byte, 27)
+	for len(packets) < n {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		if string(header[:4]) != "OggS" {
+			return nil, errors.New("Invalid Ogg page")
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, err
+		}
+
+		for _, segmentSize := range segments {
+			segment := make([]byte, segmentSize)
+			if _, err := io.ReadFull(r, segment); err != nil {
+				return nil, err
+			}
+			current = append(current, segment...)
+
+			// A segment shorter than 255 bytes ends the packet
+			if segmentSize < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+
+	return packets[:n], nil
+}
+
+func parseVorbisComment(data []byte) (audioTags, error) {
+	var tags audioTags
+	invalid := errors.New("Invalid Vorbis comment")
+
+	if len(data) < 4 {
+		return tags, invalid
+	}
+	vendorLength := int(binary.LittleEndian.Uint32(data))
+	if 4+vendorLength+4 > len(data) {
+		return tags, invalid
+	}
+	data = data[4+vendorLength:]
+
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+
+	for i := 0; i < count; i++ {
+		if len(data) < 4 {
+			return tags, invalid
+		}
+		length := int(binary.LittleEndian.Uint32(data))
+		if 4+length > len(data) {
+			return tags, invalid
+		}
+		comment := string(data[4 : 4+length])
+		data = data[4+length:]
+
+		separator := strings.IndexByte(comment, '=')
+		if separator < 0 {
+			continue
+		}
+		value := strings.TrimSpace(comment[separator+1:])
+
+		switch strings.ToUpper(comment[:separator]) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			// Artist can be repeated when several are credited
+			if tags.Artist != "" {
+				value = tags.Artist + tagValueSeparator + value
+			}
+			tags.Artist = value
+		case "ALBUM":
+			tags.Album = value
+		case "GENRE":
+			tags.Genre = value
+		case "DATE", "YEAR":
+			if tags.Year == 0 {
+				tags.Year = parseYear(value)
+			}
+		}
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// Builds an ID3v2 tag of the given version out of frames, ids mapping to the encoded contents
+func id3Tag(version byte, frames ...[2]string) []byte {
+	var body bytes.Buffer
+	for _, frame := range frames {
+		id, content := frame[0], frame[1]
+		body.WriteString(id)
+		switch version {
+		case 2:
+			body.Write([]byte{byte(len(content) >> 16), byte(len(content) >> 8), byte(len(content))})
+		case 3:
+			binary.Write(&body, binary.BigEndian, uint32(len(content)))
+			body.Write([]byte{0, 0})
+		default:
+			body.Write(syncsafeBytes(len(content)))
+			body.Write([]byte{0, 0})
+		}
+		body.WriteString(content)
+	}
+	// Padding
+	body.Write(make([]byte, 16))
+
+	tag := []byte{'I', 'D', '3', version, 0, 0}
+	tag = append(tag, syncsafeBytes(body.Len())...)
+	return append(tag, body.Bytes()...)
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+func vorbisComment(comments ...string) []byte {
+	var data bytes.Buffer
+	vendor := "test"
+	binary.Write(&data, binary.LittleEndian, uint32(len(vendor)))
+	data.WriteString(vendor)
+	binary.Write(&data, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&data, binary.LittleEndian, uint32(len(comment)))
+		data.WriteString(comment)
+	}
+	return data.Bytes()
+}
+
+// Builds an Ogg page holding whole packets
+func oggPage(granule int64, packets ...[]byte) []byte {
+	var segments, body []byte
+	for _, packet := range packets {
+		size := len(packet)
+		for ; size >= 255; size -= 255 {
+			segments = append(segments, 255)
+		}
+		segments = append(segments, byte(size))
+		body = append(body, packet...)
+	}
+
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	binary.LittleEndian.PutUint64(header[6:14], uint64(granule))
+	header[26] = byte(len(segments))
+
+	page := append(header, segments...)
+	return append(page, body...)
+}
+
+func flacBlock(blockType byte, last bool, data []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	block := []byte{blockType, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}
+	return append(block, data...)
+}
+
+func TestReadID3v2Tags(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want audioTags
+	}{
+		{
+			"v2.2",
+			id3Tag(2, [2]string{"TT2", "\x00Wonderwall"}, [2]string{"TP1", "\x00Oasis"}, [2]string{"TYE", "\x001995"}),
+			audioTags{Title: "Wonderwall", Artist: "Oasis", Year: 1995},
+		},
+		{
+			"v2.3 Latin-1 and UTF-16",
+			id3Tag(3,
+				[2]string{"TIT2", "\x00Caf\xe9"},
+				[2]string{"TPE1", "\x01\xff\xfeB\x00j\x00\xf6\x00r\x00k\x00"},
+				[2]string{"TALB", "\x03Homogenic"},
+				[2]string{"TCON", "\x00Pop"},
+				[2]string{"TYER", "\x001997"},
+			),
+			audioTags{Title: "Café", Artist: "Björk", Album: "Homogenic", Genre: "Pop", Year: 1997},
+		},
+		{
+			"v2.4 multiple values",
+			id3Tag(4, [2]string{"TPE1", "\x03Daft Punk\x00Pharrell Williams\x00"}, [2]string{"TDRC", "\x032013-05-17"}),
+			audioTags{Artist: "Daft Punk / Pharrell Williams", Year: 2013},
+		},
+		{
+			"v2.4 UTF-16BE",
+			id3Tag(4, [2]string{"TIT2", "\x02\x00O\x00k"}),
+			audioTags{Title: "Ok"},
+		},
+		{
+			"Frame larger than the tag",
+			[]byte("ID3\x03\x00\x00\x00\x00\x00\x0dTIT2\x00\x00\x00\x64\x00\x00\x00Ok"),
+			audioTags{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := readID3v2Tags(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tags != test.want {
+				t.Errorf("Got %+v, expected %+v", tags, test.want)
+			}
+		})
+	}
+}
+
+func TestReadID3v2TagsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"No tag", []byte("fLaC\x00\x00\x00\x22\x00\x00")},
+		{"Truncated body", id3Tag(3, [2]string{"TIT2", "\x00Title"})[:20]},
+	}
+
+	for _, test := range tests {
+		if _, err := readID3v2Tags(bytes.NewReader(test.data)); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
+
+func TestRemoveUnsynchronisation(t *testing.T) {
+	tests := []struct {
+		in, want []byte
+	}{
+		{[]byte{0xff, 0x00, 0xe0}, []byte{0xff, 0xe0}},
+		{[]byte{0xff, 0x00, 0x00}, []byte{0xff, 0x00}},
+		{[]byte{0xfe, 0x00}, []byte{0xfe, 0x00}},
+	}
+
+	for _, test := range tests {
+		if got := removeUnsynchronisation(test.in); !bytes.Equal(got, test.want) {
+			t.Errorf("removeUnsynchronisation(%x) = %x, expected %x", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseVorbisComment(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want audioTags
+		err  bool
+	}{
+		{
+			"Fields",
+			vorbisComment("title=Hey Jude", "ARTIST=The Beatles", "Album=Single", "GENRE=Rock", "DATE=1968-08-26"),
+			audioTags{Title: "Hey Jude", Artist: "The Beatles", Album: "Single", Genre: "Rock", Year: 1968},
+			false,
+		},
+		{
+			"Repeated artist",
+			vorbisComment("ARTIST=Simon", "ARTIST=Garfunkel", "YEAR=1970", "DATE=1971"),
+			audioTags{Artist: "Simon / Garfunkel", Year: 1970},
+			false,
+		},
+		{
+			"Comment without separator",
+			vorbisComment("nonsense", "TITLE=Ok"),
+			audioTags{Title: "Ok"},
+			false,
+		},
+		{"Too short", []byte{1, 0}, audioTags{}, true},
+		{"Truncated comment", vorbisComment("TITLE=Long title")[:20], audioTags{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := parseVorbisComment(test.data)
+			if (err != nil) != test.err {
+				t.Fatalf("Got error %v, expected one: %v", err, test.err)
+			}
+			if !test.err && tags != test.want {
+				t.Errorf("Got %+v, expected %+v", tags, test.want)
+			}
+		})
+	}
+}
+
+func TestReadFLACTags(t *testing.T) {
+	streamInfo := flacBlock(0, false, make([]byte, 34))
+	comment := flacBlock(4, true, vorbisComment("TITLE=Clair de lune", "ARTIST=Debussy"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want audioTags
+		err  bool
+	}{
+		{"Vorbis comment", append(append([]byte("fLaC"), streamInfo...), comment...), audioTags{Title: "Clair de lune", Artist: "Debussy"}, false},
+		{"No Vorbis comment", append([]byte("fLaC"), flacBlock(0, true, make([]byte, 34))...), audioTags{}, true},
+		{"Not FLAC", []byte("OggS\x00\x02"), audioTags{}, true},
+		{"Truncated", append([]byte("fLaC"), streamInfo[:10]...), audioTags{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := readFLACTags(bytes.NewReader(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("Got error %v, expected one: %v", err, test.err)
+			}
+			if tags != test.want {
+				t.Errorf("Got %+v, expected %+v", tags, test.want)
+			}
+		})
+	}
+}
+
+func TestReadOggTags(t *testing.T) {
+	// Long enough to span several segments
+	longTitle := string(bytes.Repeat([]byte("a"), 600))
+
+	tests := []struct {
+		name string
+		data []byte
+		want audioTags
+		err  bool
+	}{
+		{
+			"Vorbis",
+			append(
+				oggPage(0, []byte("\x01vorbis identification")),
+				oggPage(0, append([]byte("\x03vorbis"), vorbisComment("TITLE=Song 2", "ARTIST=Blur")...))...,
+			),
+			audioTags{Title: "Song 2", Artist: "Blur"},
+			false,
+		},
+		{
+			"Opus, headers in one page",
+			oggPage(0, []byte("OpusHead"), append([]byte("OpusTags"), vorbisComment("TITLE="+longTitle)...)),
+			audioTags{Title: longTitle},
+			false,
+		},
+		{
+			"Unknown codec",
+			oggPage(0, []byte("\x80theora"), []byte("\x81theora")),
+			audioTags{},
+			true,
+		},
+		{"Not Ogg", []byte("fLaC" + string(make([]byte, 30))), audioTags{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tags, err := readOggTags(bytes.NewReader(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("Got error %v, expected one: %v", err, test.err)
+			}
+			if tags != test.want {
+				t.Errorf("Got %+v, expected %+v", tags, test.want)
+			}
+		})
+	}
+}
+
+func TestParseYear(t *testing.T) {
+	tests := map[string]int{
+		"1999":       1999,
+		"2013-05-17": 2013,
+		"(c) 1984":   1984,
+		"84":         0,
+		"":           0,
+	}
+
+	for in, want := range tests {
+		if got := parseYear(in); got != want {
+			t.Errorf("parseYear(%q) = %v, expected %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/satori/go.uuid"
+)
+
+type Team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Sum of the members scores
+	Score int `json:"score"`
+}
+
+func newTeam(name string) *Team {
+	return &Team{
+		ID:   uuid.Must(uuid.NewV4(), nil).String(),
+		Name: name,
+	}
+}
+
+type SocketIOGameFinishedEvent struct {
+	LeaderBoard []SocketIOPlayer `json:"leaderboard"`
+	Teams       []*Team          `json:"teams"`
+}
+
+func (g *Game) getTeamByName(name string) (*Team, error) {
+	for _, team := range g.Teams {
+		if strings.EqualFold(team.Name, name) {
+			return team, nil
+		}
+	}
+
+	return nil, errors.New("Team not found")
+}
+
+// Puts the player in team, leaving their previous one
+func (g *Game) joinTeam(player *Player, team *Team) {
+	if _, err := g.getTeamByName(team.Name); err != nil {
+		g.Teams = append(g.Teams, team)
+	}
+
+	player.TeamID = team.ID
+	g.updateTeams()
+}
+
+// Returns the players of the same team as player, including them
+func (g *Game) teammates(player *Player) []*Player {
+	if player.TeamID == "" {
+		return []*Player{player}
+	}
+
+	teammates := make([]*Player, 0)
+	for _, p := range g.Players {
+		if p.TeamID == player.TeamID {
+			teammates = append(teammates, p)
+		}
+	}
+
+	return teammates
+}
+
+// Recomputes team scores from their members, and drops teams left empty
+func (g *Game) updateTeams() {
+	scores := make(map[string]int)
+	members := make(map[string]int)
+	for _, player := range g.Players {
+		scores[player.TeamID] += player.Score
+		members[player.TeamID]++
+	}
+
+	teams := make([]*Team, 0)
+	for _, team := range g.Teams {
+		if members[team.ID] == 0 {
+			continue
+		}
+
+		team.Score = scores[team.ID]
+		teams = append(teams, team)
+	}
+
+	g.Teams = teams
+}
+
+// Returns the teams from the best score down
+func (g *Game) getTeamLeaderBoard() []*Team {
+	leaderBoard := make([]*Team, len(g.Teams))
+	copy(leaderBoard, g.Teams)
+
+	sort.SliceStable(leaderBoard, func(i, j int) bool {
+		return leaderBoard[i].Score > leaderBoard[j].Score
+	})
+
+	return leaderBoard
+}
+
+// Whether the player, or their team when credit is shared, already found field this round
+func (g *Game) alreadyFound(player *Player, field string) bool {
+	if !g.Settings.SharedTeamCredit {
+		return g.CurrentRound.hasFound(player, field)
+	}
+
+	for _, teammate := range g.teammates(player) {
+		if g.CurrentRound.hasFound(teammate, field) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTeamLeaderBoard(t *testing.T) {
+	game := newGame(make([]*Player, 0), newFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), newRandom(1))
+
+	scores := map[string]int{"Alice": 10, "Bob": 40, "Carol": 25, "Dave": 5}
+	teams := map[string]string{"Alice": "Red", "Bob": "Blue", "Carol": "Green", "Dave": "Red"}
+	for _, name := range []string{"Alice", "Bob", "Carol", "Dave"} {
+		player := newPlayer(name)
+		player.Score = scores[name]
+		game.join(player)
+
+		team, err := game.getTeamByName(teams[name])
+		if err != nil {
+			team = newTeam(teams[name])
+		}
+		game.joinTeam(player, team)
+	}
+
+	leaderBoard := game.getTeamLeaderBoard()
+	want := []string{"Blue", "Green", "Red"}
+	if len(leaderBoard) != len(want) {
+		t.Fatalf("%v teams, expected %v", len(leaderBoard), len(want))
+	}
+	for i, team := range leaderBoard {
+		if team.Name != want[i] {
+			t.Errorf("Team %v is %v (%v points), expected %v", i+1, team.Name, team.Score, want[i])
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	// Events queued for a WebSocket client before it is considered too slow and dropped
+	webSocketSendBuffer = 64
+	webSocketWriteWait  = 10 * time.Second
+	webSocketPongWait   = 60 * time.Second
+	webSocketPingPeriod = webSocketPongWait * 9 / 10
+	webSocketMaxMessage = 64 * 1024
+)
+
+// Message exchanged over the WebSocket endpoint, both ways. Types and payloads are the ones of
+// the Socket.IO events.
+type WebSocketEnvelope struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// The CORS middleware strips the Origin header, so handleWebSocket puts it back for CheckOrigin
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024, CheckOrigin: checkWebSocketOrigin}
+
+// Browsers may only connect from the web client or the server itself. Other clients send no origin.
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == clientOrigin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// WebSocketClient sends room events to a WebSocket connection. Events are queued, so that a slow
+// connection never holds the room loop.
+type WebSocketClient struct {
+	id   string
+	conn *websocket.Conn
+	send chan []byte
+	// Closed once the connection is, to stop queuing events
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWebSocketClient(conn *websocket.Conn) *WebSocketClient {
+	return &WebSocketClient{
+		id:   "ws-" + uuid.Must(uuid.NewV4(), nil).String(),
+		conn: conn,
+		send: make(chan []byte, webSocketSendBuffer),
+		done: make(chan struct{}),
+	}
+}
+
+func (c *WebSocketClient) Id() string {
+	return c.id
+}
+
+// Queues the event as an envelope, its payload being the first argument
+func (c *WebSocketClient) Emit(message string, args ...interface{}) error {
+	envelope := WebSocketEnvelope{Type: message}
+	if len(args) > 0 {
+		payload, err := json.Marshal(args[0])
+		if err != nil {
+			return err
+		}
+		envelope.Payload = payload
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.done:
+		return errors.New("Connection closed")
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		log.Printf("Client %v too slow, closing", c.id)
+		c.close()
+		return errors.New("Connection too slow")
+	}
+}
+
+func (c *WebSocketClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Writes queued events, and pings the connection to detect dead ones
+func (c *WebSocketClient) writeLoop() {
+	ticker := time.NewTicker(webSocketPingPeriod)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Reads envelopes until the connection closes, playing them through conn
+func (c *WebSocketClient) readLoop(conn *Connection) {
+	defer c.close()
+	defer conn.disconnect()
+
+	c.conn.SetReadLimit(webSocketMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	})
+
+	for {
+		var envelope WebSocketEnvelope
+		if err := c.conn.ReadJSON(&envelope); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				log.Printf("Client %v read error. Err: %v", c.id, err)
+			}
+			return
+		}
+
+		c.dispatch(conn, envelope)
+	}
+}
+
+// Payloads of the client messages
+type webSocketPayload struct {
+	RoomCode   string  `json:"room_code"`
+	PlayerName string  `json:"player_name"`
+	Token      string  `json:"token"`
+	Guess      *string `json:"guess"`
+	Option     *int    `json:"option"`
+	TeamName   string  `json:"team_name"`
+}
+
+func (c *WebSocketClient) dispatch(conn *Connection, envelope WebSocketEnvelope) {
+	var payload webSocketPayload
+	if len(envelope.Payload) > 0 {
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+			c.Emit("error", "Invalid payload")
+			return
+		}
+	}
+
+	switch envelope.Type {
+	case "join":
+		if payload.RoomCode == "" || payload.PlayerName == "" {
+			c.Emit("error", "Field 'room_code' and 'player_name' required")
+			return
+		}
+		conn.join(payload.RoomCode, payload.PlayerName)
+	case "playerReconnect":
+		if payload.Token == "" {
+			c.Emit("error", "Field 'token' required")
+			return
+		}
+		conn.reconnect(payload.Token)
+	case "configure":
+		var params map[string]interface{}
+		if err := json.Unmarshal(envelope.Payload, &params); err != nil {
+			c.Emit("error", "Invalid payload")
+			return
+		}
+		conn.configure(params)
+	case "start":
+		conn.start()
+	case "buzz":
+		conn.buzz()
+	case "choose":
+		if payload.Option == nil {
+			c.Emit("error", "Field 'option' required")
+			return
+		}
+		conn.choose(*payload.Option)
+	case "guess":
+		if payload.Guess == nil {
+			c.Emit("error", "Field 'guess' required")
+			return
+		}
+		conn.guess(*payload.Guess)
+	case "joinTeam", "switchTeam":
+		if payload.TeamName == "" {
+			c.Emit("error", "Field 'team_name' required")
+			return
+		}
+		conn.joinTeam(payload.TeamName, envelope.Type == "switchTeam")
+	case "leave":
+		conn.leave()
+	default:
+		c.Emit("error", "Unknown message type")
+	}
+}
+
+// Upgrades the request to a WebSocket connection playing the game
+func handleWebSocket(c *gin.Context) {
+	if origin := c.GetString(originContextKey); origin != "" {
+		c.Request.Header.Set("Origin", origin)
+	}
+
+	wsConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Can't upgrade to WebSocket. Err: %v", err)
+		return
+	}
+
+	client := newWebSocketClient(wsConn)
+	log.Printf("WebSocket %v connected", client.Id())
+
+	go client.writeLoop()
+	client.readLoop(newConnection(client))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := initRouter()
+	router.GET("ws", handleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{clientOrigin, true},
+		{server.URL, true},
+		{"http://evil.example.com", false},
+		{"http://localhost:8082", false},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		if test.origin != "" {
+			header.Set("Origin", test.origin)
+		}
+
+		conn, response, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if conn != nil {
+			conn.Close()
+		}
+
+		if test.allowed && err != nil {
+			t.Errorf("Origin %q refused: %v", test.origin, err)
+		}
+		if !test.allowed && (err == nil || response == nil || response.StatusCode != http.StatusForbidden) {
+			t.Errorf("Origin %q accepted", test.origin)
+		}
+	}
+}